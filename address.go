@@ -0,0 +1,74 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Indian postal PIN code. Six digits with first digit non zero,
+	// sometimes written with a space after first three digits.
+	pincodeRegexp = regexp.MustCompile(`\b([1-9][0-9]{2})\s?([0-9]{3})\b`)
+	// Query which is just a PIN code
+	pincodeQueryRegexp = regexp.MustCompile(`^([1-9][0-9]{2})\s?([0-9]{3})$`)
+	// Landmark phrases in address. For example "NEAR BUS STAND"
+	landmarkRegexp = regexp.MustCompile(`\b(?:NEAR|OPP|OPPOSITE|BEHIND|NEXT TO|ADJACENT TO|ADJ TO)\b\.?\s*([^,]+)`)
+	// Address parts which are door numbers, floors, plots etc.
+	doorNumberRegexp = regexp.MustCompile(`^(?:NO|DOOR NO|PLOT NO|SHOP NO|H NO|#)?[\s.:]*[0-9][0-9/\-]*[A-Z]?$`)
+)
+
+// Extract pincode, landmark and locality from bank address.
+// Fields which are already set are not overwritten.
+func parseAddress(bank *Bank) {
+	address := strings.ToUpper(bank.Address)
+
+	// Last PIN code in the address is usually the branch PIN code
+	if bank.Pincode == "" {
+		if matches := pincodeRegexp.FindAllStringSubmatch(address, -1); len(matches) > 0 {
+			m := matches[len(matches)-1]
+			bank.Pincode = m[1] + m[2]
+		}
+	}
+
+	if bank.Landmark == "" {
+		if m := landmarkRegexp.FindStringSubmatch(address); m != nil {
+			bank.Landmark = strings.TrimSpace(m[1])
+		}
+	}
+
+	if bank.Locality == "" {
+		bank.Locality = extractLocality(address, bank)
+	}
+}
+
+// Get locality from address. Locality is the last part of the comma separated
+// address which is not a door number, landmark, city, district or state.
+func extractLocality(address string, bank *Bank) string {
+	parts := strings.Split(pincodeRegexp.ReplaceAllString(address, ""), ",")
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.Trim(strings.TrimSpace(parts[i]), ".-")
+		if part == "" || doorNumberRegexp.MatchString(part) || landmarkRegexp.MatchString(part) {
+			continue
+		}
+
+		if strings.EqualFold(part, bank.City) || strings.EqualFold(part, bank.District) ||
+			strings.EqualFold(part, bank.State) {
+			continue
+		}
+
+		return part
+	}
+
+	return ""
+}
+
+// Get PIN code if the query is just a PIN code
+func parsePincodeQuery(q string) (string, bool) {
+	m := pincodeQueryRegexp.FindStringSubmatch(strings.TrimSpace(q))
+	if m == nil {
+		return "", false
+	}
+
+	return m[1] + m[2], true
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		bank     Bank
+		pincode  string
+		landmark string
+		locality string
+	}{
+		{
+			Bank{Address: "NO 30, 10TH MAIN ROAD, JAYANAGAR 4TH BLOCK, BANGALORE 560011", City: "BANGALORE"},
+			"560011", "", "JAYANAGAR 4TH BLOCK",
+		},
+		{
+			Bank{Address: "No 1, 80 Feet Road, Koramangala, Bangalore 560 034", City: "BANGALORE"},
+			"560034", "", "KORAMANGALA",
+		},
+		{
+			Bank{Address: "SHOP NO 5, NEAR BUS STAND, MG ROAD, MYSORE", City: "MYSORE"},
+			"", "BUS STAND", "MG ROAD",
+		},
+		{
+			Bank{Address: "PLOT NO 12, OPP. RAILWAY STATION, STATION ROAD, PUNE, MAHARASHTRA", City: "PUNE", State: "MAHARASHTRA"},
+			"", "RAILWAY STATION", "STATION ROAD",
+		},
+		// Last PIN code is the branch PIN code
+		{
+			Bank{Address: "C/O HEAD OFFICE 400001, FORT, MUMBAI 400023", City: "MUMBAI"},
+			"400023", "", "FORT",
+		},
+		// Fields already in data are kept
+		{
+			Bank{Address: "MG ROAD, BANGALORE 560001", City: "BANGALORE", Pincode: "560002", Locality: "BRIGADE ROAD"},
+			"560002", "", "BRIGADE ROAD",
+		},
+		{Bank{Address: ""}, "", "", ""},
+	}
+
+	for _, c := range cases {
+		bank := c.bank
+		parseAddress(&bank)
+		if bank.Pincode != c.pincode || bank.Landmark != c.landmark || bank.Locality != c.locality {
+			t.Errorf("parseAddress(%q) = (%q, %q, %q), want (%q, %q, %q)", c.bank.Address,
+				bank.Pincode, bank.Landmark, bank.Locality, c.pincode, c.landmark, c.locality)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+// Analytics event types
+const (
+	eventSearch = "search"
+	eventIFSC   = "ifsc"
+)
+
+// Maximum number of events written in a single transaction
+const analyticsBatchSize = 100
+
+var (
+	// Events queued to be written to store
+	analyticsEvents chan *AnalyticsEvent
+	// Closed when writer has flushed all events
+	analyticsDone chan struct{}
+)
+
+// AnalyticsEvent is a search or IFSC lookup recorded for analytics
+type AnalyticsEvent struct {
+	Time         time.Time         `json:"time"`
+	Type         string            `json:"type"`
+	Query        string            `json:"query"`
+	Abbreviation string            `json:"abbreviation,omitempty"`
+	Filters      map[string]string `json:"filters,omitempty"`
+	Hits         uint64            `json:"hits"`
+	Latency      float64           `json:"latency"`
+	ClientIP     string            `json:"client_ip,omitempty"`
+}
+
+// AnalyticsCount is number of events for a query or IFSC
+type AnalyticsCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// TrendingIFSC is number of lookups of an IFSC in current and previous window
+type TrendingIFSC struct {
+	IFSC     string `json:"ifsc"`
+	Count    int    `json:"count"`
+	Previous int    `json:"previous"`
+}
+
+// AnalyticsReport is summary of analytics events in a time window
+type AnalyticsReport struct {
+	From                 time.Time        `json:"from"`
+	To                   time.Time        `json:"to"`
+	Searches             int              `json:"searches"`
+	ZeroResultSearches   int              `json:"zero_result_searches"`
+	TopQueries           []AnalyticsCount `json:"top_queries"`
+	TopZeroResultQueries []AnalyticsCount `json:"top_zero_result_queries"`
+	TrendingIFSC         []TrendingIFSC   `json:"trending_ifsc"`
+}
+
+// Start writing analytics events to store in background
+func startAnalytics() {
+	if db == nil || !cfg().GetBool("analytics_enabled") {
+		return
+	}
+
+	analyticsEvents = make(chan *AnalyticsEvent, 1000)
+	analyticsDone = make(chan struct{})
+	go analyticsWriter(analyticsEvents, analyticsDone)
+}
+
+// Stop analytics and wait for queued events to be written
+func stopAnalytics() {
+	if analyticsEvents == nil {
+		return
+	}
+
+	close(analyticsEvents)
+	<-analyticsDone
+	analyticsEvents = nil
+}
+
+// Queue analytics event. Event is dropped if queue is full
+// so that requests are never blocked by analytics.
+func recordAnalytics(e *AnalyticsEvent) {
+	if analyticsEvents == nil {
+		return
+	}
+
+	if cfg().GetBool("analytics_anonymize_ip") {
+		e.ClientIP = anonymizeIP(e.ClientIP)
+	}
+
+	select {
+	case analyticsEvents <- e:
+	default:
+		log.Debug("Analytics queue is full, event dropped.")
+	}
+}
+
+// Write queued events to store in batches
+func analyticsWriter(events <-chan *AnalyticsEvent, done chan<- struct{}) {
+	defer close(done)
+
+	for e := range events {
+		batch := []*AnalyticsEvent{e}
+
+		// Collect already queued events
+	collect:
+		for len(batch) < analyticsBatchSize {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, e)
+			default:
+				break collect
+			}
+		}
+
+		if err := writeAnalyticsEvents(batch); err != nil {
+			log.Errorf("Error writing analytics events: %v", err)
+		}
+	}
+}
+
+// Append events to analytics bucket. Keys are event time followed by
+// a sequence number so that events are ordered by time.
+func writeAnalyticsEvents(events []*AnalyticsEvent) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(analyticsBucket)
+		for _, e := range events {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			value, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+
+			key := make([]byte, 16)
+			binary.BigEndian.PutUint64(key[:8], uint64(e.Time.UnixNano()))
+			binary.BigEndian.PutUint64(key[8:], seq)
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Build analytics report for given window ending now. IFSC lookups
+// are compared against the previous window of same length for trends.
+func analyticsReport(window time.Duration, limit int) (*AnalyticsReport, error) {
+	to := time.Now()
+	from := to.Add(-window)
+	report := &AnalyticsReport{From: from, To: to}
+
+	var (
+		queries     = make(map[string]int)
+		zeroQueries = make(map[string]int)
+		ifscs       = make(map[string]int)
+		prevIFSCs   = make(map[string]int)
+	)
+
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, uint64(from.Add(-window).UnixNano()))
+	split := make([]byte, 8)
+	binary.BigEndian.PutUint64(split, uint64(from.UnixNano()))
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(analyticsBucket).Cursor()
+		for k, v := c.Seek(start); k != nil; k, v = c.Next() {
+			var e AnalyticsEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+
+			// Previous window is only used for IFSC trends
+			if bytes.Compare(k[:8], split) < 0 {
+				if e.Type == eventIFSC {
+					prevIFSCs[e.Query]++
+				}
+				continue
+			}
+
+			switch e.Type {
+			case eventSearch:
+				report.Searches++
+				queries[e.Query]++
+				if e.Hits == 0 {
+					report.ZeroResultSearches++
+					zeroQueries[e.Query]++
+				}
+			case eventIFSC:
+				ifscs[e.Query]++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.TopQueries = topAnalyticsCounts(queries, limit)
+	report.TopZeroResultQueries = topAnalyticsCounts(zeroQueries, limit)
+
+	// IFSCs ordered by increase in lookups from previous window
+	report.TrendingIFSC = []TrendingIFSC{}
+	for ifsc, count := range ifscs {
+		report.TrendingIFSC = append(report.TrendingIFSC, TrendingIFSC{ifsc, count, prevIFSCs[ifsc]})
+	}
+	sort.Slice(report.TrendingIFSC, func(i, j int) bool {
+		a, b := report.TrendingIFSC[i], report.TrendingIFSC[j]
+		if a.Count-a.Previous != b.Count-b.Previous {
+			return a.Count-a.Previous > b.Count-b.Previous
+		}
+		return a.IFSC < b.IFSC
+	})
+	if len(report.TrendingIFSC) > limit {
+		report.TrendingIFSC = report.TrendingIFSC[:limit]
+	}
+
+	return report, nil
+}
+
+// Get terms with highest counts
+func topAnalyticsCounts(counts map[string]int, limit int) []AnalyticsCount {
+	top := make([]AnalyticsCount, 0, len(counts))
+	for term, count := range counts {
+		top = append(top, AnalyticsCount{term, count})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Term < top[j].Term
+	})
+
+	if len(top) > limit {
+		top = top[:limit]
+	}
+
+	return top
+}
+
+// Anonymize IP by zeroing host part. Last octet of IPv4
+// and last 80 bits of IPv6 addresses are removed.
+func anonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
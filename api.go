@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-
 	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/blevesearch/bleve"
-	"github.com/spf13/viper"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // DefaultResponse Error response structure
@@ -28,13 +41,66 @@ type SeachResultItem struct {
 
 // SearchResultsResponse is a response structure for final search results
 type SearchResultsResponse struct {
+	Total             uint64            `json:"total"`
 	TotalResultsPages uint64            `json:"total_results_pages"`
 	MoreResults       bool              `json:"more_results"`
 	Page              int               `json:"page"`
+	Size              int               `json:"size"`
+	NextCursor        string            `json:"next_cursor,omitempty"`
 	Time              string            `json:"took"`
 	Results           []SeachResultItem `json:"results"`
 }
 
+// SearchExplainItem is a response structure for search result item with score explanation
+type SearchExplainItem struct {
+	ID          string              `json:"id"`
+	Score       float64             `json:"score"`
+	Fields      interface{}         `json:"fields"`
+	Explanation *search.Explanation `json:"explanation"`
+}
+
+// SearchExplainResponse is a response structure for search debug endpoint
+type SearchExplainResponse struct {
+	Query          string              `json:"query"`
+	FormattedQuery string              `json:"formatted_query"`
+	Abbreviation   string              `json:"abbreviation"`
+	QueryTree      query.Query         `json:"query_tree"`
+	Total          uint64              `json:"total"`
+	Time           string              `json:"took"`
+	Results        []SearchExplainItem `json:"results"`
+}
+
+// StatusResponse is a response structure for service status
+type StatusResponse struct {
+	Status       string `json:"status"`
+	BuildVersion string `json:"build_version"`
+	BuildDate    string `json:"build_date"`
+	Uptime       string `json:"uptime"`
+	DataRelease  string `json:"data_release,omitempty"`
+	DocCount     uint64 `json:"doc_count"`
+	IndexSize    int64  `json:"index_size"`
+	Banks        int    `json:"banks"`
+}
+
+// BulkIFSCResponse is a response structure for bulk IFSC lookup
+type BulkIFSCResponse struct {
+	Total    int          `json:"total"`
+	Found    int          `json:"found"`
+	NotFound int          `json:"not_found"`
+	Invalid  int          `json:"invalid"`
+	Results  []IFSCResult `json:"results"`
+}
+
+// Opaque search cursor which holds sort values of the
+// last result of previous page for deep paging
+type searchCursor struct {
+	Sort  string   `json:"s"`
+	After []string `json:"a"`
+}
+
+// Returned by server when active requests aren't drained within shutdown timeout
+var errShutdownIncomplete = errors.New("server shutdown timed out with active requests")
+
 // Adapter type
 type Adapter func(http.Handler) http.Handler
 
@@ -47,12 +113,194 @@ func Adapt(h http.Handler, adapters ...Adapter) http.Handler {
 	return h
 }
 
-// Log all requests
+// Response writer which records response status and size
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader records status and writes it to underlying response writer
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records number of bytes written to underlying response writer
+func (w *responseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap returns underlying response writer for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Request context key type
+type contextKey string
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = contextKey("request_id")
+	apiKeyHeader    = "X-API-Key"
+	apiKeyKey       = contextKey("api_key")
+	// Maximum length of request id accepted from client
+	maxRequestIDLength = 128
+)
+
+// Generate random request id
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Get request id from request context
+func getRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// Get logger with request id field for handler logs
+func requestLogger(r *http.Request) *log.Entry {
+	return log.WithField("request_id", getRequestID(r))
+}
+
+// Get client IP from remote address. If request is from a trusted
+// proxy then the last untrusted address in X-Forwarded-For is used.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+
+		host = ip
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+
+	return host
+}
+
+// Log all requests with status, size and latency. Request id is taken
+// from X-Request-ID header or generated, and is sent back in response.
 func HttpLogger() Adapter {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			defer log.Infof("%s %s", r.Method, r.RequestURI)
-			h.ServeHTTP(w, r)
+			startTime := time.Now()
+
+			id := r.Header.Get(requestIDHeader)
+			if id == "" || len(id) > maxRequestIDLength {
+				id = newRequestID()
+			}
+
+			w.Header().Set(requestIDHeader, id)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+			rw := newResponseWriter(w)
+			h.ServeHTTP(rw, r)
+
+			log.WithFields(log.Fields{
+				"request_id": id,
+				"method":     r.Method,
+				"uri":        r.RequestURI,
+				"status":     rw.status,
+				"bytes":      rw.bytes,
+				"duration":   time.Since(startTime).Seconds(),
+				"client_ip":  clientIP(r),
+				"user_agent": r.UserAgent(),
+			}).Info("Request")
+		})
+	}
+}
+
+// Check if request has admin token in Authorization header
+func isAdminToken(r *http.Request) bool {
+	token := cfg().GetString("admin_token")
+	if token == "" {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	return subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) == 1
+}
+
+// Get API key of authenticated request, nil for anonymous requests
+func getAPIKey(r *http.Request) *APIKey {
+	k, _ := r.Context().Value(apiKeyKey).(*APIKey)
+	return k
+}
+
+// Authenticate requests with API key in X-API-Key header which has given
+// scope and count its usage. Requests without API key are allowed if
+// anonymous access is enabled, except admin requests which require either
+// an API key with admin scope or the admin token.
+func APIKeyAuth(scope string) Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := r.Header.Get(apiKeyHeader)
+			if secret == "" {
+				if scope == scopeAdmin && isAdminToken(r) ||
+					scope != scopeAdmin && cfg().GetBool("api_anonymous_access") {
+					h.ServeHTTP(w, r)
+					return
+				}
+
+				writeJSONResponse(w, DefaultResponse{"API key is required."}, http.StatusUnauthorized)
+				return
+			}
+
+			if db == nil {
+				writeJSONResponse(w, DefaultResponse{"API keys are temporarily unavailable."}, http.StatusServiceUnavailable)
+				return
+			}
+
+			k, err := findAPIKey(secret)
+			if err == nil {
+				if !k.hasScope(scope) {
+					writeJSONResponse(w, DefaultResponse{"API key doesn't have " + scope + " scope."}, http.StatusForbidden)
+					return
+				}
+
+				err = useAPIKey(k)
+			}
+
+			switch err {
+			case nil:
+			case errAPIKeyNotFound:
+				writeJSONResponse(w, DefaultResponse{"Invalid API key."}, http.StatusUnauthorized)
+				return
+			case errAPIQuotaExceeded:
+				writeJSONResponse(w, DefaultResponse{"API key daily quota exceeded."}, http.StatusTooManyRequests)
+				return
+			default:
+				requestLogger(r).Errorf("Error while checking API key: %v", err)
+				writeJSONResponse(w, DefaultResponse{"Something went wrong. Please report to admin."}, http.StatusInternalServerError)
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyKey, k)))
 		})
 	}
 }
@@ -76,23 +324,158 @@ func sanatizeSearchQuery(query string) (string, error) {
 	return strings.ToLower(query), nil
 }
 
+// Parse optional latitude and longitude query params
+func parseCoordinates(lat string, lon string) (*float64, *float64, error) {
+	if lat == "" && lon == "" {
+		return nil, nil, nil
+	}
+
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return nil, nil, errors.New("Invalid latitude.")
+	}
+
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return nil, nil, errors.New("Invalid longitude.")
+	}
+
+	return &latitude, &longitude, nil
+}
+
+// Encode search cursor as url safe string
+func encodeSearchCursor(c searchCursor) string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode search cursor and validate it against current sort option
+func decodeSearchCursor(cursor string, sortBy string) (searchCursor, error) {
+	var c searchCursor
+
+	if !isCursorSort(sortBy) {
+		return c, errors.New("Search cursor requires sort by bank, branch, city or distance.")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, errors.New("Invalid search cursor.")
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil || len(c.After) == 0 {
+		return c, errors.New("Invalid search cursor.")
+	}
+
+	if c.Sort != sortBy {
+		return c, errors.New("Search cursor doesn't match sort option.")
+	}
+
+	return c, nil
+}
+
+// Respond with service unavailable error if search index is not loaded
+func checkSearchIndex(w http.ResponseWriter) bool {
+	if bankIndex == nil {
+		writeJSONResponse(w, DefaultResponse{"Search is temporarily unavailable."}, http.StatusServiceUnavailable)
+		return false
+	}
+
+	return true
+}
+
 // Index page handler
 func indexHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSONResponse(w, DefaultResponse{"Bankr API v3"}, http.StatusOK)
 }
 
+// Liveness probe handler
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, DefaultResponse{"ok"}, http.StatusOK)
+}
+
+// Readiness probe handler. Ready only if search index is open,
+// has documents and banks list is loaded.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if bankIndex == nil {
+		writeJSONResponse(w, DefaultResponse{"Search index is not loaded."}, http.StatusServiceUnavailable)
+		return
+	}
+
+	count, err := bankIndex.DocCount()
+	if err != nil {
+		requestLogger(r).Errorf("Error getting index doc count: %v", err)
+		writeJSONResponse(w, DefaultResponse{"Search index is not readable."}, http.StatusServiceUnavailable)
+		return
+	}
+
+	if count == 0 {
+		writeJSONResponse(w, DefaultResponse{"Search index is empty."}, http.StatusServiceUnavailable)
+		return
+	}
+
+	if len(banksList) == 0 {
+		writeJSONResponse(w, DefaultResponse{"Banks list is not loaded."}, http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSONResponse(w, DefaultResponse{"ready"}, http.StatusOK)
+}
+
+// Service status handler
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	status := StatusResponse{
+		Status:       "ok",
+		BuildVersion: buildVersion,
+		BuildDate:    buildDate,
+		Uptime:       time.Since(startedAt).String(),
+		Banks:        len(banksList),
+	}
+
+	if !dataReleaseDate.IsZero() {
+		status.DataRelease = dataReleaseDate.Format(time.RFC3339)
+	}
+
+	// Server is running without search index
+	if bankIndex == nil {
+		status.Status = "degraded"
+		writeJSONResponse(w, status, http.StatusOK)
+		return
+	}
+
+	count, err := bankIndex.DocCount()
+	if err != nil {
+		requestLogger(r).Errorf("Error getting index doc count: %v", err)
+		status.Status = "degraded"
+	}
+	status.DocCount = count
+
+	size, err := dirSize(cfg().GetString("search_index_path"))
+	if err != nil {
+		requestLogger(r).Errorf("Error getting index size: %v", err)
+	}
+	status.IndexSize = size
+
+	writeJSONResponse(w, status, http.StatusOK)
+}
+
 func getGeocodeAddressHandler(w http.ResponseWriter, r *http.Request) {
 	latitude := r.URL.Query().Get("latitude")
 	longitude := r.URL.Query().Get("longitude")
-	geocodeApiKey := viper.GetString("geocode_api_key")
-	geocodeAPIURI := viper.GetString("geocode_api_uri")
+	geocodeApiKey := cfg().GetString("geocode_api_key")
+	geocodeAPIURI := cfg().GetString("geocode_api_uri")
 
 	client := &http.Client{}
 	request, err := http.NewRequest("GET", geocodeAPIURI, nil)
 
 	if err != nil {
-		log.Errorf("Error while getting location: %v", err)
+		geocodeErrorsTotal.Inc()
+		requestLogger(r).Errorf("Error while getting location: %v", err)
 		writeJSONResponse(w, DefaultResponse{"Error while getting location"}, http.StatusBadGateway)
+		return
 	}
 
 	// Add query params to the request
@@ -101,37 +484,95 @@ func getGeocodeAddressHandler(w http.ResponseWriter, r *http.Request) {
 	q.Add("key", geocodeApiKey)
 	request.URL.RawQuery = q.Encode()
 
+	startTime := time.Now()
 	resp, err := client.Do(request)
+	geocodeRequestDuration.Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		geocodeErrorsTotal.Inc()
+		requestLogger(r).Errorf("Error while getting location: %v", err)
+		writeJSONResponse(w, DefaultResponse{"Error while getting location"}, http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		geocodeErrorsTotal.Inc()
+	}
+
 	responseData, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
-		log.Errorf("Error while parsing location response: %v", err)
+		geocodeErrorsTotal.Inc()
+		requestLogger(r).Errorf("Error while parsing location response: %v", err)
 		writeJSONResponse(w, DefaultResponse{"Error while getting location"}, http.StatusBadGateway)
+		return
 	}
 
 	var response map[string]interface{}
 	err = json.Unmarshal(responseData, &response)
 
 	if err != nil {
-		log.Errorf("Error while parsing unmarshaling response: %v", err)
+		geocodeErrorsTotal.Inc()
+		requestLogger(r).Errorf("Error while parsing unmarshaling response: %v", err)
 		writeJSONResponse(w, DefaultResponse{"Error while getting location"}, http.StatusBadGateway)
+		return
 	}
 
 	writeJSONResponse(w, response, http.StatusOK)
 }
 
+// Record search for analytics. Queries which are an IFSC
+// are also recorded as IFSC lookups.
+func recordSearchAnalytics(r *http.Request, query string, abb string, sortBy string, nearby bool, total uint64, took time.Duration) {
+	filters := make(map[string]string)
+	if sortBy != "" {
+		filters["sort"] = sortBy
+	}
+	if nearby {
+		filters["location"] = "true"
+	}
+
+	e := &AnalyticsEvent{
+		Time:         time.Now(),
+		Type:         eventSearch,
+		Query:        query,
+		Abbreviation: abb,
+		Filters:      filters,
+		Hits:         total,
+		Latency:      took.Seconds(),
+		ClientIP:     clientIP(r),
+	}
+	recordAnalytics(e)
+
+	if ifsc := strings.ToUpper(query); ifscRegexp.MatchString(ifsc) {
+		l := *e
+		l.Type = eventIFSC
+		l.Query = ifsc
+		recordAnalytics(&l)
+	}
+}
+
 // Query search handler
 func searchHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	page := r.URL.Query().Get("p")
+	size := r.URL.Query().Get("size")
+	sortBy := r.URL.Query().Get("sort")
+	cursor := r.URL.Query().Get("search_after")
+
+	if !checkSearchIndex(w) {
+		return
+	}
 
 	var (
 		errorResponse        DefaultResponse
 		searchResults        *bleve.SearchResult
 		searchResultItems    []SeachResultItem
-		resultsSize          = 10
+		searchAfter          []string
+		resultsSize          = cfg().GetInt("results_size")
 		pageNumber           = 1
 		moreResultsAvailable = false
+		nextCursor           = ""
 	)
 
 	// Validate search query
@@ -143,28 +584,112 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate page number
-	if page == "" {
-		pageNumber = 1
-	} else {
+	if page != "" {
 		pageNumber, err = strconv.Atoi(page)
-		if err != nil {
+		if err != nil || pageNumber < 1 {
 			errorResponse.Message = "Invalid page number."
 			writeJSONResponse(w, errorResponse, http.StatusBadRequest)
 			return
 		}
 	}
 
-	// Search for give query and result size (startIndex + size). Start index is (pageNum - 1)
-	searchResults, err = querySearch(query, resultsSize, pageNumber-1)
+	// Validate results size
+	if size != "" {
+		resultsSize, err = strconv.Atoi(size)
+		if err != nil || resultsSize < 1 || resultsSize > cfg().GetInt("max_results_size") {
+			errorResponse.Message = fmt.Sprintf("Invalid size. Size should be between 1 and %d.", cfg().GetInt("max_results_size"))
+			writeJSONResponse(w, errorResponse, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate sort option and coordinates for distance sort
+	latitude, longitude, err := parseCoordinates(r.URL.Query().Get("lat"), r.URL.Query().Get("lon"))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		writeJSONResponse(w, errorResponse, http.StatusBadRequest)
+		return
+	}
+
+	sortOrder, err := buildSortOrder(sortBy, latitude, longitude)
+	if err != nil {
+		errorResponse.Message = err.Error()
+		writeJSONResponse(w, errorResponse, http.StatusBadRequest)
+		return
+	}
+
+	// Validate search cursor. Page number is ignored if cursor is given.
+	if cursor != "" {
+		c, err := decodeSearchCursor(cursor, sortBy)
+		if err != nil {
+			errorResponse.Message = err.Error()
+			writeJSONResponse(w, errorResponse, http.StatusBadRequest)
+			return
+		}
+
+		searchAfter = c.After
+		pageNumber = 0
+	}
+
+	// Results offset for page number based paging. Pages are limited
+	// to max_result_window results, deeper pages require a cursor.
+	from := 0
+	if searchAfter == nil {
+		maxWindow := cfg().GetInt("max_result_window")
+		if pageNumber > maxWindow/resultsSize {
+			errorResponse.Message = fmt.Sprintf("Page is beyond the first %d results. Sort by a field and use search_after with next_cursor for deeper pages.", maxWindow)
+			writeJSONResponse(w, errorResponse, http.StatusBadRequest)
+			return
+		}
+
+		from = (pageNumber - 1) * resultsSize
+	}
+
+	// Respond with 304 if client has results of current data release
+	if checkNotModified(w, r) {
+		return
+	}
+
+	// Serve from cache if same search was done on current index
+	searchQuery, formattedQuery, abb := buildSearchQuery(query)
+	cacheKey := searchCacheKey(query, sortBy, latitude, longitude, resultsSize, pageNumber, cursor)
+	if v, ok := searchCache.get(cacheKey); ok {
+		cached := v.(cachedSearch)
+		searchCacheHitsTotal.Inc()
+		observeSearch(cached.response.Total, cached.took, true)
+		recordSearchAnalytics(r, formattedQuery, abb, sortBy, latitude != nil, cached.response.Total, cached.took)
+		writeJSONResponse(w, cached.response, http.StatusOK)
+		return
+	}
+	searchCacheMissesTotal.Inc()
+
+	// Search for given query with an extra result to check if more results are available
+	searchResults, err = searchBanks(newSearchRequest(searchQuery, resultsSize+1, from, sortOrder, searchAfter))
 	if err != nil {
-		log.Errorf("Error while searching query: %v", err)
+		requestLogger(r).Errorf("Error while searching query: %v", err)
 		errorResponse.Message = "Something went wrong. Please report to admin."
 		writeJSONResponse(w, errorResponse, http.StatusInternalServerError)
 		return
 	}
 
+	observeSearch(searchResults.Total, searchResults.Took, false)
+	recordSearchAnalytics(r, formattedQuery, abb, sortBy, latitude != nil, searchResults.Total, searchResults.Took)
+
+	// Check if more available and create cursor from last result of this page
+	hits := searchResults.Hits
+	if len(hits) > resultsSize {
+		hits = hits[:resultsSize]
+		moreResultsAvailable = true
+		if isCursorSort(sortBy) {
+			nextCursor = encodeSearchCursor(searchCursor{
+				Sort:  sortBy,
+				After: hits[len(hits)-1].Sort,
+			})
+		}
+	}
+
 	// Create list for search items response
-	for _, result := range searchResults.Hits {
+	for _, result := range hits {
 		searchResultItems = append(searchResultItems, SeachResultItem{
 			ID:     result.ID,
 			Score:  result.Score,
@@ -172,38 +697,430 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Check if more available
-	if searchResults.Total > uint64(pageNumber+resultsSize) {
-		moreResultsAvailable = true
-	}
-
 	// Final search response
 	searchResultsResponse := SearchResultsResponse{
-		TotalResultsPages: searchResults.Total - 1,
+		Total:             searchResults.Total,
+		TotalResultsPages: (searchResults.Total + uint64(resultsSize) - 1) / uint64(resultsSize),
 		MoreResults:       moreResultsAvailable,
 		Page:              pageNumber,
+		Size:              resultsSize,
+		NextCursor:        nextCursor,
 		Time:              searchResults.Took.String(),
 		Results:           searchResultItems,
 	}
 
-	log.Infof("Searched for term q=%v - %v results generated in %v nanoseconds", query, searchResults.Total, searchResults.Took.Nanoseconds())
+	searchCache.add(cacheKey, cachedSearch{searchResultsResponse, searchResults.Took})
+
+	requestLogger(r).WithFields(log.Fields{
+		"query":    query,
+		"total":    searchResults.Total,
+		"duration": searchResults.Took.Seconds(),
+	}).Info("Searched")
 
 	// Write the output
 	writeJSONResponse(w, searchResultsResponse, http.StatusOK)
 }
 
-func initServer(address string) {
+// Search debug handler which explains query rewriting and scoring.
+// Available only when debug is enabled, which can change on reload.
+func searchExplainHandler(w http.ResponseWriter, r *http.Request) {
+	if !cfg().GetBool("debug") {
+		writeJSONResponse(w, DefaultResponse{"Not found."}, http.StatusNotFound)
+		return
+	}
+
+	rawQuery := r.URL.Query().Get("q")
+
+	if !checkSearchIndex(w) {
+		return
+	}
+
+	var (
+		errorResponse     DefaultResponse
+		searchResultItems []SearchExplainItem
+	)
+
+	// Validate search query
+	q, err := sanatizeSearchQuery(rawQuery)
+	if err != nil {
+		errorResponse.Message = err.Error()
+		writeJSONResponse(w, errorResponse, http.StatusBadRequest)
+		return
+	}
+
+	searchQuery, formattedQuery, abb := buildSearchQuery(q)
+	searchRequest := newSearchRequest(searchQuery, cfg().GetInt("results_size"), 0, nil, nil)
+	searchRequest.Explain = true
+
+	searchResults, err := searchBanks(searchRequest)
+	if err != nil {
+		requestLogger(r).Errorf("Error while searching query: %v", err)
+		errorResponse.Message = "Something went wrong. Please report to admin."
+		writeJSONResponse(w, errorResponse, http.StatusInternalServerError)
+		return
+	}
+
+	for _, result := range searchResults.Hits {
+		searchResultItems = append(searchResultItems, SearchExplainItem{
+			ID:          result.ID,
+			Score:       result.Score,
+			Fields:      result.Fields,
+			Explanation: result.Expl,
+		})
+	}
+
+	writeJSONResponse(w, SearchExplainResponse{
+		Query:          rawQuery,
+		FormattedQuery: formattedQuery,
+		Abbreviation:   abb,
+		QueryTree:      searchQuery,
+		Total:          searchResults.Total,
+		Time:           searchResults.Took.String(),
+		Results:        searchResultItems,
+	}, http.StatusOK)
+}
+
+// Search analytics report handler
+func analyticsReportHandler(w http.ResponseWriter, r *http.Request) {
+	if db == nil || !cfg().GetBool("analytics_enabled") {
+		writeJSONResponse(w, DefaultResponse{"Analytics is disabled."}, http.StatusServiceUnavailable)
+		return
+	}
+
+	var (
+		window = cfg().GetDuration("analytics_window")
+		limit  = cfg().GetInt("analytics_report_size")
+		err    error
+	)
+
+	// Validate report window, for example 1h or 168h
+	if v := r.URL.Query().Get("window"); v != "" {
+		window, err = time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			writeJSONResponse(w, DefaultResponse{"Invalid window."}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate number of entries in each list
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeJSONResponse(w, DefaultResponse{"Invalid limit."}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := analyticsReport(window, limit)
+	if err != nil {
+		requestLogger(r).Errorf("Error while building analytics report: %v", err)
+		writeJSONResponse(w, DefaultResponse{"Something went wrong. Please report to admin."}, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, report, http.StatusOK)
+}
+
+// Bulk IFSC lookup handler. Accepts a JSON array or newline delimited IFSCs.
+func bulkIFSCHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, DefaultResponse{"Method not allowed."}, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkSearchIndex(w) {
+		return
+	}
+
+	// Limit request body size to maximum number of IFSCs with some room for separators
+	maxIFSCs := cfg().GetInt("bulk_max_ifscs")
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxIFSCs)*32+1024)
+
+	codes, err := parseIFSCList(r.Body, maxIFSCs)
+	if err != nil {
+		writeJSONResponse(w, DefaultResponse{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	startTime := time.Now()
+	response := BulkIFSCResponse{
+		Total:   len(codes),
+		Results: lookupIFSCs(codes, cfg().GetInt("bulk_workers")),
+	}
+
+	for _, res := range response.Results {
+		switch res.Status {
+		case ifscFound:
+			response.Found++
+		case ifscNotFound:
+			response.NotFound++
+		case ifscInvalid:
+			response.Invalid++
+			continue
+		default:
+			continue
+		}
+
+		recordAnalytics(&AnalyticsEvent{
+			Time:     time.Now(),
+			Type:     eventIFSC,
+			Query:    res.IFSC,
+			ClientIP: clientIP(r),
+		})
+	}
+
+	requestLogger(r).WithFields(log.Fields{
+		"total":     response.Total,
+		"found":     response.Found,
+		"not_found": response.NotFound,
+		"invalid":   response.Invalid,
+		"duration":  time.Since(startTime).Seconds(),
+	}).Info("Bulk IFSC lookup")
+
+	writeJSONResponse(w, response, http.StatusOK)
+}
+
+// CSV enrichment handler. Accepts CSV as request body or as file in multipart
+// form and streams it back with bank details of IFSC column appended.
+func enrichCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, DefaultResponse{"Method not allowed."}, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkSearchIndex(w) {
+		return
+	}
+
+	column := r.URL.Query().Get("column")
+	if column == "" {
+		column = "IFSC"
+	}
+
+	// Enriched rows are written while upload is still being read, which HTTP/1.x
+	// server doesn't allow unless full duplex is enabled. Large files can take
+	// longer than server timeouts so deadlines are extended for this request.
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil {
+		requestLogger(r).Debugf("Full duplex not enabled: %v", err)
+	}
+
+	deadline := time.Now().Add(cfg().GetDuration("enrich_timeout"))
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		requestLogger(r).Debugf("Read deadline not set: %v", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		requestLogger(r).Debugf("Write deadline not set: %v", err)
+	}
+
+	// Read first file in multipart form without buffering it
+	var in io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			writeJSONResponse(w, DefaultResponse{"Invalid multipart form."}, http.StatusBadRequest)
+			return
+		}
+
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				writeJSONResponse(w, DefaultResponse{"CSV file is required."}, http.StatusBadRequest)
+				return
+			}
+
+			if part.FileName() != "" {
+				in = part
+				break
+			}
+		}
+	}
+
+	enricher, err := newCSVEnricher(in, column)
+	if err != nil {
+		writeJSONResponse(w, DefaultResponse{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="enriched.csv"`)
+
+	// Response is already streaming, errors can only be logged
+	startTime := time.Now()
+	count, err := enricher.enrich(w, cfg().GetInt("bulk_workers"))
+	if err != nil {
+		requestLogger(r).Errorf("Error while enriching CSV after %d rows: %v", count, err)
+		return
+	}
+
+	requestLogger(r).WithFields(log.Fields{
+		"rows":     count,
+		"duration": time.Since(startTime).Seconds(),
+	}).Info("Enriched CSV")
+}
+
+// APIKeyRequest is a request structure for creating API key
+type APIKeyRequest struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	DailyQuota uint64   `json:"daily_quota"`
+}
+
+// APIKeyResponse is a response structure for created API key
+type APIKeyResponse struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// API keys admin handler. Lists keys on GET, creates a key on POST
+// and revokes a key on DELETE /api/admin/keys/<id>.
+func apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		writeJSONResponse(w, DefaultResponse{"API keys are temporarily unavailable."}, http.StatusServiceUnavailable)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/keys"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		keys, err := listAPIKeys()
+		if err != nil {
+			requestLogger(r).Errorf("Error while listing API keys: %v", err)
+			writeJSONResponse(w, DefaultResponse{"Something went wrong. Please report to admin."}, http.StatusInternalServerError)
+			return
+		}
+
+		for _, k := range keys {
+			k.Hash = ""
+		}
+
+		writeJSONResponse(w, keys, http.StatusOK)
+	case r.Method == http.MethodPost && id == "":
+		var req APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONResponse(w, DefaultResponse{"Invalid request body."}, http.StatusBadRequest)
+			return
+		}
+
+		if strings.TrimSpace(req.Name) == "" {
+			writeJSONResponse(w, DefaultResponse{"Name is required."}, http.StatusBadRequest)
+			return
+		}
+
+		if len(req.Scopes) == 0 {
+			writeJSONResponse(w, DefaultResponse{"Atleast one scope is required."}, http.StatusBadRequest)
+			return
+		}
+
+		for _, scope := range req.Scopes {
+			if !isValidScope(scope) {
+				writeJSONResponse(w, DefaultResponse{fmt.Sprintf("Invalid scope %s.", scope)}, http.StatusBadRequest)
+				return
+			}
+		}
+
+		k, secret, err := createAPIKey(strings.TrimSpace(req.Name), req.Scopes, req.DailyQuota)
+		if err != nil {
+			requestLogger(r).Errorf("Error while creating API key: %v", err)
+			writeJSONResponse(w, DefaultResponse{"Something went wrong. Please report to admin."}, http.StatusInternalServerError)
+			return
+		}
+
+		k.Hash = ""
+		requestLogger(r).Infof("Created API key %s (%s)", k.ID, k.Name)
+		writeJSONResponse(w, APIKeyResponse{k, secret}, http.StatusCreated)
+	case r.Method == http.MethodDelete && id != "":
+		if err := revokeAPIKey(id); err == errAPIKeyNotFound {
+			writeJSONResponse(w, DefaultResponse{"API key not found."}, http.StatusNotFound)
+			return
+		} else if err != nil {
+			requestLogger(r).Errorf("Error while revoking API key: %v", err)
+			writeJSONResponse(w, DefaultResponse{"Something went wrong. Please report to admin."}, http.StatusInternalServerError)
+			return
+		}
+
+		requestLogger(r).Infof("Revoked API key %s", id)
+		writeJSONResponse(w, DefaultResponse{"API key revoked."}, http.StatusOK)
+	default:
+		writeJSONResponse(w, DefaultResponse{"Method not allowed."}, http.StatusMethodNotAllowed)
+	}
+}
+
+// Wrap API handler with given adapters followed by CORS, metrics and request logging
+func apiHandler(route string, h http.HandlerFunc, adapters ...Adapter) http.Handler {
+	return Adapt(h, append(adapters, CORS(), Metrics(route), HttpLogger())...)
+}
+
+// Start the server and block until it's shutdown on SIGINT or SIGTERM.
+// Active connections are drained within the shutdown timeout, returns
+// errShutdownIncomplete if requests were still active after it.
+func initServer(address string) error {
+	mux := http.NewServeMux()
+
 	// Server static files
-	http.Handle("/", http.FileServer(http.Dir("./frontend/dist/")))
+	mux.Handle("/", Adapt(http.FileServer(http.Dir("./frontend/dist/")), Metrics("/")))
 
 	// API handlers
-	http.Handle("/api", Adapt(http.HandlerFunc(indexHandler)))
-	http.Handle("/api/search", Adapt(http.HandlerFunc(searchHandler), HttpLogger()))
-	http.Handle("/api/location", Adapt(http.HandlerFunc(getGeocodeAddressHandler), HttpLogger()))
+	mux.Handle("/api", Adapt(http.HandlerFunc(indexHandler), CORS(), Metrics("/api")))
+	mux.Handle("/api/search", apiHandler("/api/search", searchHandler, APIKeyAuth(scopeSearch), RateLimit("search")))
+	mux.Handle("/api/location", apiHandler("/api/location", getGeocodeAddressHandler, APIKeyAuth(scopeSearch), RateLimit("location")))
+	mux.Handle("/api/ifsc/bulk", apiHandler("/api/ifsc/bulk", bulkIFSCHandler, APIKeyAuth(scopeLookup), RateLimit("lookup")))
+	mux.Handle("/api/ifsc/enrich", apiHandler("/api/ifsc/enrich", enrichCSVHandler, APIKeyAuth(scopeLookup), RateLimit("lookup")))
+	mux.Handle("/api/status", apiHandler("/api/status", statusHandler))
+
+	// Admin handlers
+	mux.Handle("/api/admin/analytics", apiHandler("/api/admin/analytics", analyticsReportHandler, APIKeyAuth(scopeAdmin), RateLimit("admin")))
+	apiKeys := apiHandler("/api/admin/keys", apiKeysHandler, APIKeyAuth(scopeAdmin), RateLimit("admin"))
+	mux.Handle("/api/admin/keys", apiKeys)
+	mux.Handle("/api/admin/keys/", apiKeys)
+
+	// Health check and metrics handlers
+	mux.Handle("/healthz", http.HandlerFunc(healthHandler))
+	mux.Handle("/readyz", http.HandlerFunc(readyHandler))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// Debug handlers, which respond only when debug is enabled
+	mux.Handle("/api/debug/search", apiHandler("/api/debug/search", searchExplainHandler, APIKeyAuth(scopeSearch), RateLimit("search")))
+
+	server := &http.Server{
+		Addr:         address,
+		Handler:      mux,
+		ReadTimeout:  cfg().GetDuration("read_timeout"),
+		WriteTimeout: cfg().GetDuration("write_timeout"),
+		IdleTimeout:  cfg().GetDuration("idle_timeout"),
+	}
+
+	// Shutdown server on SIGINT or SIGTERM
+	shutdown := make(chan struct{})
+	var shutdownErr error
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		log.Infof("Received %v, shutting down server.", <-sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg().GetDuration("shutdown_timeout"))
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			log.Error("Error shutting down the server: ", err)
+			shutdownErr = errShutdownIncomplete
+		}
+
+		close(shutdown)
+	}()
 
 	// Start the server
 	log.Infof("Starting server: http://%s", address)
-	if err := http.ListenAndServe(address, nil); err != nil {
-		log.Error("Error starting the server: ", err)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
 	}
+
+	<-shutdown
+	if shutdownErr != nil {
+		return shutdownErr
+	}
+
+	log.Info("Server stopped.")
+	return nil
 }
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Call search handler with given query params and decode response
+func searchFixture(t *testing.T, params url.Values) (int, SearchResultsResponse) {
+	req := httptest.NewRequest("GET", "/api/search?"+params.Encode(), nil)
+	rec := httptest.NewRecorder()
+	searchHandler(rec, req)
+
+	var resp SearchResultsResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Error while decoding search response: %v", err)
+		}
+	}
+
+	return rec.Code, resp
+}
+
+func TestSearchHandlerPaging(t *testing.T) {
+	const query, size = "bangalore", 3
+
+	all, err := querySearch(query, 100, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("Error while searching %q: %v", query, err)
+	}
+
+	expected := hitIFSCs(all.Hits)
+	totalPages := (len(expected) + size - 1) / size
+	if totalPages < 2 {
+		t.Fatalf("Expected %q to match more than one page, got %d results", query, len(expected))
+	}
+
+	for page := 1; page <= totalPages+1; page++ {
+		code, resp := searchFixture(t, url.Values{
+			"q":    {query},
+			"size": {"3"},
+			"p":    {strconv.Itoa(page)},
+		})
+		if code != http.StatusOK {
+			t.Fatalf("Page %d: status = %d, want %d", page, code, http.StatusOK)
+		}
+
+		from, to := (page-1)*size, page*size
+		if from > len(expected) {
+			from = len(expected)
+		}
+		if to > len(expected) {
+			to = len(expected)
+		}
+
+		got := []string{}
+		for _, r := range resp.Results {
+			got = append(got, r.ID)
+		}
+
+		if !reflect.DeepEqual(got, expected[from:to]) {
+			t.Errorf("Page %d: results = %v, want %v", page, got, expected[from:to])
+		}
+
+		if resp.Total != uint64(len(expected)) || resp.TotalResultsPages != uint64(totalPages) {
+			t.Errorf("Page %d: total = %d, pages = %d, want %d, %d",
+				page, resp.Total, resp.TotalResultsPages, len(expected), totalPages)
+		}
+
+		if more := page < totalPages; resp.MoreResults != more {
+			t.Errorf("Page %d: more_results = %v, want %v", page, resp.MoreResults, more)
+		}
+	}
+}
+
+// Walk all pages with search cursors and compare with page number paging
+func TestSearchHandlerCursorPaging(t *testing.T) {
+	const query = "bangalore"
+
+	for _, sortBy := range []string{"bank", "branch", "city"} {
+		params := url.Values{"q": {query}, "size": {"2"}, "sort": {sortBy}}
+
+		var expected []string
+		for page := 1; ; page++ {
+			params.Set("p", strconv.Itoa(page))
+			code, resp := searchFixture(t, params)
+			if code != http.StatusOK {
+				t.Fatalf("sort=%s page %d: status = %d, want %d", sortBy, page, code, http.StatusOK)
+			}
+
+			for _, r := range resp.Results {
+				expected = append(expected, r.ID)
+			}
+
+			if !resp.MoreResults {
+				break
+			}
+		}
+		params.Del("p")
+
+		var got []string
+		for {
+			code, resp := searchFixture(t, params)
+			if code != http.StatusOK {
+				t.Fatalf("sort=%s cursor %s: status = %d, want %d", sortBy, params.Get("search_after"), code, http.StatusOK)
+			}
+
+			for _, r := range resp.Results {
+				got = append(got, r.ID)
+			}
+
+			if resp.NextCursor == "" {
+				if resp.MoreResults {
+					t.Fatalf("sort=%s: more results without next cursor", sortBy)
+				}
+				break
+			}
+
+			params.Set("search_after", resp.NextCursor)
+		}
+
+		if len(expected) < 3 || !reflect.DeepEqual(got, expected) {
+			t.Errorf("sort=%s: cursor paging = %v, page paging = %v", sortBy, got, expected)
+		}
+	}
+}
+
+// Results sorted by score can't be paged with cursors
+func TestSearchHandlerScoreCursor(t *testing.T) {
+	code, resp := searchFixture(t, url.Values{"q": {"bangalore"}, "size": {"2"}})
+	if code != http.StatusOK || !resp.MoreResults || resp.NextCursor != "" {
+		t.Errorf("Score sort: status = %d, more_results = %v, next_cursor = %q, want 200, true, empty",
+			code, resp.MoreResults, resp.NextCursor)
+	}
+
+	cursor := encodeSearchCursor(searchCursor{After: []string{"_score", "CNRB0000430"}})
+	code, _ = searchFixture(t, url.Values{"q": {"bangalore"}, "search_after": {cursor}})
+	if code != http.StatusBadRequest {
+		t.Errorf("Score sort with cursor: status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestSearchHandlerResultWindow(t *testing.T) {
+	defer viper.Set("max_result_window", viper.GetInt("max_result_window"))
+	viper.Set("max_result_window", 5)
+
+	cases := []struct {
+		page   string
+		status int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusBadRequest},
+		{"9223372036854775807", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		code, _ := searchFixture(t, url.Values{"q": {"bangalore"}, "size": {"3"}, "p": {c.page}})
+		if code != c.status {
+			t.Errorf("Page %s: status = %d, want %d", c.page, code, c.status)
+		}
+	}
+}
+
+func TestSearchETagChangesWithRanking(t *testing.T) {
+	etag := func() string {
+		req := httptest.NewRequest("GET", "/api/search?q=bangalore", nil)
+		rec := httptest.NewRecorder()
+		searchHandler(rec, req)
+		return rec.Header().Get("ETag")
+	}
+
+	loadRankingVersion()
+	before := etag()
+	if before == "" {
+		t.Fatal("Expected ETag in search response")
+	}
+
+	cityBoost := viper.GetFloat64("boost.city")
+	defer func() {
+		viper.Set("boost.city", cityBoost)
+		loadFieldBoosts()
+		loadRankingVersion()
+	}()
+	viper.Set("boost.city", 10.0)
+	loadFieldBoosts()
+	loadRankingVersion()
+
+	if after := etag(); after == before {
+		t.Errorf("ETag %s didn't change after boosts changed", after)
+	}
+}
+
+func TestSearchHandlerDistanceSort(t *testing.T) {
+	code, _ := searchFixture(t, url.Values{
+		"q":    {"bangalore"},
+		"sort": {"distance"},
+		"lat":  {"12.93"},
+		"lon":  {"77.58"},
+	})
+	if code != http.StatusBadRequest {
+		t.Errorf("Distance sort on index without locations: status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// Upload CSV larger than what server reads ahead before closing an
+// unread request body to check enriched output is streamed while
+// the upload is still being read.
+func TestEnrichCSVHandlerStreaming(t *testing.T) {
+	server := httptest.NewServer(Adapt(http.HandlerFunc(enrichCSVHandler), HttpLogger()))
+	defer server.Close()
+
+	codes := []string{"SBIN0003236", "HDFC0000053", "INVALID", "SBIN0999999"}
+	padding := strings.Repeat("x", 200)
+
+	var body bytes.Buffer
+	body.WriteString("NAME,IFSC,NOTE\n")
+	rows := 10 * enrichChunkSize
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&body, "row%d,%s,%s\n", i, codes[i%len(codes)], padding)
+	}
+
+	if body.Len() < 1<<20 {
+		t.Fatalf("Upload is only %d bytes, expected at least 1MB", body.Len())
+	}
+
+	// Hide body length so that it's uploaded with chunked encoding
+	resp, err := http.Post(server.URL+"?column=ifsc", "text/csv", io.MultiReader(&body))
+	if err != nil {
+		t.Fatalf("Error while posting CSV: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Error while reading enriched CSV: %v", err)
+	}
+
+	if len(records) != rows+1 {
+		t.Fatalf("Enriched CSV has %d rows, want %d", len(records)-1, rows)
+	}
+
+	header := strings.Join(records[0], ",")
+	if want := "NAME,IFSC,NOTE,BANK,BRANCH,CITY,STATE,MICR,IFSC_STATUS"; header != want {
+		t.Errorf("Header = %s, want %s", header, want)
+	}
+
+	for i, record := range records[1:] {
+		status := record[len(record)-1]
+		want := []string{"found", "found", "invalid", "not_found"}[i%len(codes)]
+		if record[0] != fmt.Sprintf("row%d", i) || status != want {
+			t.Fatalf("Row %d = %v, want row%d with status %s", i, record, i, want)
+		}
+	}
+
+	if branch := records[1][4]; branch != "JAYANAGAR" {
+		t.Errorf("Branch of %s = %s, want JAYANAGAR", codes[0], branch)
+	}
+}
+
+// Contacts are a list even if branch has a single contact
+func TestContactsList(t *testing.T) {
+	const ifsc = "SBIN0003236"
+
+	contacts := func(body []byte, path string) {
+		var resp struct {
+			Results []struct {
+				ID     string `json:"id"`
+				IFSC   string `json:"ifsc"`
+				Fields struct {
+					Contacts json.RawMessage `json:"contacts"`
+				} `json:"fields"`
+				Bank struct {
+					Contacts json.RawMessage `json:"contacts"`
+				} `json:"bank"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("%s: error while decoding response: %v", path, err)
+		}
+
+		for _, r := range resp.Results {
+			if r.ID != ifsc && r.IFSC != ifsc {
+				continue
+			}
+
+			raw := r.Fields.Contacts
+			if raw == nil {
+				raw = r.Bank.Contacts
+			}
+
+			if want := `["+918022261717"]`; string(raw) != want {
+				t.Errorf("%s: contacts of %s = %s, want %s", path, ifsc, raw, want)
+			}
+			return
+		}
+
+		t.Errorf("%s: %s not in results", path, ifsc)
+	}
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest("GET", "/api/search?q=jayanagar", nil))
+	contacts(rec.Body.Bytes(), "/api/search")
+
+	rec = httptest.NewRecorder()
+	bulkIFSCHandler(rec, httptest.NewRequest("POST", "/api/ifsc/bulk", strings.NewReader(`["`+ifsc+`"]`)))
+	contacts(rec.Body.Bytes(), "/api/ifsc/bulk")
+}
+
+// Debug endpoint follows debug config on each request since it's reloaded
+func TestSearchExplainHandlerDebug(t *testing.T) {
+	debug := viper.GetBool("debug")
+	defer viper.Set("debug", debug)
+
+	for _, enabled := range []bool{false, true, false} {
+		viper.Set("debug", enabled)
+
+		rec := httptest.NewRecorder()
+		searchExplainHandler(rec, httptest.NewRequest("GET", "/api/debug/search?q=jayanagar", nil))
+
+		want := http.StatusNotFound
+		if enabled {
+			want = http.StatusOK
+		}
+
+		if rec.Code != want {
+			t.Errorf("debug=%v: status = %d, want %d", enabled, rec.Code, want)
+		}
+	}
+}
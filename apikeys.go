@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// API key scopes
+const (
+	scopeSearch = "search"
+	scopeLookup = "lookup"
+	scopeAdmin  = "admin"
+)
+
+// Prefix of generated API keys
+const apiKeyPrefix = "bk_"
+
+var (
+	// API keys by hash of the key
+	apiKeysBucket = []byte("api_keys")
+	// Daily usage counters by key id and date
+	apiUsageBucket = []byte("api_usage")
+	// Valid API key scopes
+	apiKeyScopes = [...]string{scopeSearch, scopeLookup, scopeAdmin}
+
+	errAPIKeyNotFound   = errors.New("API key not found")
+	errAPIQuotaExceeded = errors.New("API key daily quota exceeded")
+)
+
+// APIKey is an API key issued to a client. Only hash of the key is stored.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	DailyQuota uint64     `json:"daily_quota"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Hash       string     `json:"hash,omitempty"`
+	UsageToday uint64     `json:"usage_today"`
+}
+
+// Check if API key has given scope
+func (k *APIKey) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if scope is valid
+func isValidScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Generate random hex string of given number of bytes
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Get hash of API key used as its storage key
+func hashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// Key of usage counter for API key on given day
+func apiUsageKey(id string, t time.Time) []byte {
+	return []byte(id + ":" + t.UTC().Format("2006-01-02"))
+}
+
+// Create API key. Returns created key and the secret key which is not stored.
+func createAPIKey(name string, scopes []string, dailyQuota uint64) (*APIKey, string, error) {
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = apiKeyPrefix + secret
+
+	k := &APIKey{
+		ID:         id,
+		Name:       name,
+		Scopes:     scopes,
+		DailyQuota: dailyQuota,
+		CreatedAt:  time.Now(),
+		Hash:       hashAPIKey(secret),
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		value, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(apiKeysBucket).Put([]byte(k.Hash), value)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return k, secret, nil
+}
+
+// Get all API keys with today's usage
+func listAPIKeys() ([]*APIKey, error) {
+	keys := []*APIKey{}
+	err := db.View(func(tx *bolt.Tx) error {
+		usage := tx.Bucket(apiUsageBucket)
+		return tx.Bucket(apiKeysBucket).ForEach(func(_, v []byte) error {
+			var k APIKey
+			if err := json.Unmarshal(v, &k); err != nil {
+				return err
+			}
+
+			if count := usage.Get(apiUsageKey(k.ID, time.Now())); count != nil {
+				k.UsageToday = binary.BigEndian.Uint64(count)
+			}
+
+			keys = append(keys, &k)
+			return nil
+		})
+	})
+
+	return keys, err
+}
+
+// Revoke API key by its id
+func revokeAPIKey(id string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+		c := b.Cursor()
+		for hash, v := c.First(); hash != nil; hash, v = c.Next() {
+			var k APIKey
+			if err := json.Unmarshal(v, &k); err != nil {
+				return err
+			}
+
+			if k.ID != id {
+				continue
+			}
+
+			if k.RevokedAt == nil {
+				now := time.Now()
+				k.RevokedAt = &now
+			}
+
+			value, err := json.Marshal(k)
+			if err != nil {
+				return err
+			}
+
+			return b.Put(hash, value)
+		}
+
+		return errAPIKeyNotFound
+	})
+}
+
+// Get active API key by its secret with today's usage. Returns
+// errAPIKeyNotFound for unknown or revoked keys. Lookups are read only
+// so invalid keys don't cost a write.
+func findAPIKey(secret string) (*APIKey, error) {
+	var k APIKey
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(apiKeysBucket).Get([]byte(hashAPIKey(secret)))
+		if v == nil {
+			return errAPIKeyNotFound
+		}
+
+		if err := json.Unmarshal(v, &k); err != nil {
+			return err
+		}
+
+		if k.RevokedAt != nil {
+			return errAPIKeyNotFound
+		}
+
+		if count := tx.Bucket(apiUsageBucket).Get(apiUsageKey(k.ID, time.Now())); count != nil {
+			k.UsageToday = binary.BigEndian.Uint64(count)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// Count a request against API key's usage for today. Returns
+// errAPIQuotaExceeded if key has used its daily quota.
+func useAPIKey(k *APIKey) error {
+	if k.DailyQuota > 0 && k.UsageToday >= k.DailyQuota {
+		return errAPIQuotaExceeded
+	}
+
+	// Batch usage updates of concurrent requests into a single transaction
+	return db.Batch(func(tx *bolt.Tx) error {
+		usage := tx.Bucket(apiUsageBucket)
+		key := apiUsageKey(k.ID, time.Now())
+
+		var count uint64
+		if v := usage.Get(key); v != nil {
+			count = binary.BigEndian.Uint64(v)
+		}
+
+		if k.DailyQuota > 0 && count >= k.DailyQuota {
+			k.UsageToday = count
+			return errAPIQuotaExceeded
+		}
+
+		count++
+		k.UsageToday = count
+
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, count)
+		return usage.Put(key, b)
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// IFSC lookup statuses
+const (
+	ifscFound    = "found"
+	ifscNotFound = "not_found"
+	ifscInvalid  = "invalid"
+	ifscError    = "error"
+)
+
+// IFSCResult is result of looking up a single IFSC
+type IFSCResult struct {
+	IFSC   string      `json:"ifsc"`
+	Status string      `json:"status"`
+	Bank   interface{} `json:"bank,omitempty"`
+}
+
+// Parse IFSCs from a JSON array of strings or newline delimited IFSCs,
+// where each line is either a plain IFSC or a JSON string. Returns an
+// error if there are more than max IFSCs.
+func parseIFSCList(r io.Reader, max int) ([]string, error) {
+	br := bufio.NewReader(r)
+
+	// Detect JSON array from first non space character
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return []string{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			break
+		}
+		br.ReadByte()
+	}
+
+	if b, _ := br.Peek(1); b[0] == '[' {
+		var codes []string
+		if err := json.NewDecoder(br).Decode(&codes); err != nil {
+			return nil, errors.New("Invalid JSON array of IFSCs.")
+		}
+
+		if len(codes) > max {
+			return nil, tooManyIFSCsError(max)
+		}
+
+		return codes, nil
+	}
+
+	codes := []string{}
+	scanner := bufio.NewScanner(br)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, `"`) {
+			if err := json.Unmarshal([]byte(line), &line); err != nil {
+				return nil, errors.New("Invalid JSON string in line.")
+			}
+		}
+
+		if len(codes) == max {
+			return nil, tooManyIFSCsError(max)
+		}
+
+		codes = append(codes, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Error returned when there are more IFSCs than allowed
+func tooManyIFSCsError(max int) error {
+	return fmt.Errorf("Too many IFSCs, maximum allowed is %d.", max)
+}
+
+// Lookup IFSCs in search index with given number of concurrent workers.
+// Results are in the same order as given IFSCs.
+func lookupIFSCs(codes []string, workers int) []IFSCResult {
+	results := make([]IFSCResult, len(codes))
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = lookupIFSCResult(codes[i])
+			}
+		}()
+	}
+
+	for i := range codes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// Lookup a single IFSC and get its status
+func lookupIFSCResult(code string) IFSCResult {
+	ifsc := strings.ToUpper(strings.TrimSpace(code))
+	if !ifscRegexp.MatchString(ifsc) {
+		return IFSCResult{IFSC: code, Status: ifscInvalid}
+	}
+
+	hit, err := lookupIFSC(ifsc)
+	if err != nil {
+		log.Errorf("Error while looking up IFSC %s: %v", ifsc, err)
+		return IFSCResult{IFSC: ifsc, Status: ifscError}
+	}
+
+	if hit == nil {
+		return IFSCResult{IFSC: ifsc, Status: ifscNotFound}
+	}
+
+	return IFSCResult{IFSC: ifsc, Status: ifscFound, Bank: hit.Fields}
+}
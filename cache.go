@@ -0,0 +1,205 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Cache of search responses. Purged when search index is swapped.
+	searchCache = newLRUCache(0)
+	// Version of ranking config and when it last changed at runtime
+	ranking struct {
+		sync.RWMutex
+		version  string
+		modified time.Time
+	}
+)
+
+// Cached search response and the index query time it took
+type cachedSearch struct {
+	response SearchResultsResponse
+	took     time.Duration
+}
+
+// Least recently used cache. Cache of size zero is disabled.
+type lruCache struct {
+	sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Get value from cache and mark it as recently used
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// Add value to cache, evicting least recently used values if its full
+func (c *lruCache) add(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key, value})
+	c.evict()
+}
+
+// Change cache size, evicting least recently used values if required
+func (c *lruCache) resize(size int) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.size = size
+	c.evict()
+}
+
+// Remove all values from cache
+func (c *lruCache) purge() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Remove least recently used values exceeding cache size
+func (c *lruCache) evict() {
+	for c.order.Len() > c.size && c.order.Len() > 0 {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*lruEntry).key)
+	}
+}
+
+// Load search cache size from config
+func loadSearchCache() {
+	searchCache.resize(cfg().GetInt("cache_size"))
+}
+
+// Purge search cache, for example when ranking changes
+func purgeSearchCache() {
+	searchCache.purge()
+}
+
+// Get cache key of search from query and its filters. Query isn't formatted
+// since different queries are formatted the same but run different index
+// queries, for example a PIN code alone and a PIN code with other words.
+func searchCacheKey(query string, sortBy string, lat, lon *float64, size int, page int, cursor string) string {
+	location := ""
+	if lat != nil && lon != nil {
+		location = fmt.Sprintf("%f,%f", *lat, *lon)
+	}
+
+	return strings.Join([]string{
+		query, sortBy, location, fmt.Sprint(size), fmt.Sprint(page), cursor,
+	}, "\x00")
+}
+
+// Load version of ranking config from field boosts and default results
+// size, which change search responses when reloaded
+func loadRankingVersion() {
+	boosts := getFieldBoosts()
+	parts := []string{fmt.Sprint(cfg().GetInt("results_size"))}
+	for _, field := range boostedFields {
+		parts = append(parts, fmt.Sprintf("%s=%v", field, boosts[field]))
+	}
+	version := strings.Join(parts, ",")
+
+	ranking.Lock()
+	defer ranking.Unlock()
+
+	if ranking.version != "" && ranking.version != version {
+		ranking.modified = time.Now()
+	}
+	ranking.version = version
+}
+
+// Get ranking config version and time of its last change
+func rankingVersion() (string, time.Time) {
+	ranking.RLock()
+	defer ranking.RUnlock()
+
+	return ranking.version, ranking.modified
+}
+
+// Get version of served data which changes with data release, build and ranking config
+func dataVersion() string {
+	version, _ := rankingVersion()
+	h := sha1.Sum([]byte(dataReleaseDate.UTC().Format(time.RFC3339Nano) + buildVersion + version))
+	return hex.EncodeToString(h[:8])
+}
+
+// Set ETag and Last-Modified headers from data release and ranking config and
+// respond with 304 Not Modified if client already has response of current data.
+func checkNotModified(w http.ResponseWriter, r *http.Request) bool {
+	if dataReleaseDate.IsZero() {
+		return false
+	}
+
+	etag := `"` + dataVersion() + `"`
+	lastModified := dataReleaseDate
+	if _, modified := rankingVersion(); modified.After(lastModified) {
+		lastModified = modified
+	}
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	// If-None-Match takes precedence over If-Modified-Since
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, m := range strings.Split(match, ",") {
+			m = strings.TrimPrefix(strings.TrimSpace(m), "W/")
+			if m == etag || m == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
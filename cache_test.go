@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestSearchCacheKey(t *testing.T) {
+	// PIN code query runs a term query on pincode, with other words
+	// its formatted to the same PIN code but searches all fields
+	queries := []string{"560034", "560034 bank"}
+	for _, q := range queries {
+		if _, formatted, _ := buildSearchQuery(q); formatted != "560034" {
+			t.Fatalf("buildSearchQuery(%q) formatted = %q, want 560034", q, formatted)
+		}
+	}
+
+	if searchCacheKey(queries[0], "", nil, nil, 10, 1, "") == searchCacheKey(queries[1], "", nil, nil, 10, 1, "") {
+		t.Errorf("Queries %q have the same cache key", queries)
+	}
+}
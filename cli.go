@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// CLI subcommand
+type command struct {
+	name  string
+	args  string
+	usage string
+	// Flags and the config keys they override
+	flags func(fs *pflag.FlagSet) map[string]string
+	run   func(fs *pflag.FlagSet) error
+}
+
+// Get list of commands. Flag defaults are taken from config
+// so this has to be called after config is initialized.
+func newCommands() []command {
+	return []command{
+		{
+			name:  "serve",
+			usage: "Start the web server",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				fs.String("address", cfg().GetString("address"), "address to listen on")
+				fs.Bool("degraded", cfg().GetBool("degraded_mode"), "start without search index if it fails to load")
+				return map[string]string{"address": "address", "degraded": "degraded_mode"}
+			},
+			run: serveCommand,
+		},
+		{
+			name:  "index",
+			args:  "build|update|stats",
+			usage: "Build a new search index, update existing index or show index stats",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				fs.String("data", cfg().GetString("data_path"), "banks data CSV file path")
+				fs.String("index", cfg().GetString("search_index_path"), "search index path")
+				fs.Int("batch-size", cfg().GetInt("batch_size"), "index batch size")
+				fs.Int("workers", cfg().GetInt("index_workers"), "number of indexing workers")
+				fs.Float64("max-invalid-ratio", cfg().GetFloat64("validation_max_invalid_ratio"), "maximum ratio of invalid rows allowed")
+				return map[string]string{
+					"data":              "data_path",
+					"index":             "search_index_path",
+					"batch-size":        "batch_size",
+					"workers":           "index_workers",
+					"max-invalid-ratio": "validation_max_invalid_ratio",
+				}
+			},
+			run: indexCommand,
+		},
+		{
+			name:  "validate",
+			args:  "<csv>",
+			usage: "Validate banks data CSV file and print the report",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				fs.Float64("max-invalid-ratio", cfg().GetFloat64("validation_max_invalid_ratio"), "maximum ratio of invalid rows allowed")
+				fs.Int("max-rows", cfg().GetInt("validation_report_max_rows"), "maximum offending rows listed in the report")
+				return map[string]string{
+					"max-invalid-ratio": "validation_max_invalid_ratio",
+					"max-rows":          "validation_report_max_rows",
+				}
+			},
+			run: validateCommand,
+		},
+		{
+			name:  "query",
+			args:  "<text>",
+			usage: "Search the index and print results",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				fs.String("data", cfg().GetString("data_path"), "banks data CSV file path")
+				fs.String("index", cfg().GetString("search_index_path"), "search index path")
+				fs.Int("size", cfg().GetInt("results_size"), "number of results")
+				fs.String("sort", "", "sort by score, bank, branch or city")
+				return map[string]string{
+					"data":  "data_path",
+					"index": "search_index_path",
+					"size":  "results_size",
+				}
+			},
+			run: queryCommand,
+		},
+		{
+			name:  "lookup",
+			args:  "<ifsc>",
+			usage: "Lookup bank details by IFSC",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				fs.String("data", cfg().GetString("data_path"), "banks data CSV file path")
+				fs.String("index", cfg().GetString("search_index_path"), "search index path")
+				return map[string]string{
+					"data":  "data_path",
+					"index": "search_index_path",
+				}
+			},
+			run: lookupCommand,
+		},
+		{
+			name:  "enrich",
+			args:  "<csv>",
+			usage: "Append bank details of IFSC column to CSV file",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				fs.String("data", cfg().GetString("data_path"), "banks data CSV file path")
+				fs.String("index", cfg().GetString("search_index_path"), "search index path")
+				fs.String("column", "IFSC", "name of IFSC column")
+				fs.String("output", "", "output CSV file path, defaults to stdout")
+				fs.Int("workers", cfg().GetInt("bulk_workers"), "number of concurrent lookups")
+				return map[string]string{
+					"data":    "data_path",
+					"index":   "search_index_path",
+					"workers": "bulk_workers",
+				}
+			},
+			run: enrichCommand,
+		},
+		{
+			name:  "config",
+			args:  "print",
+			usage: "Print the effective configuration",
+			flags: func(fs *pflag.FlagSet) map[string]string {
+				return nil
+			},
+			run: configCommand,
+		},
+	}
+}
+
+// Print usage of all commands
+func printUsage(commands []command) {
+	fmt.Fprintf(os.Stderr, "Usage: %s [--config path] <command> [flags] [args]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-28s %s\n", strings.TrimSpace(c.name+" "+c.args), c.usage)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> --help' for command flags.\n", os.Args[0])
+}
+
+// Parse subcommand and its flags and run it. Runs serve if no command is given.
+func runCommand(args []string) error {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+
+	commands := newCommands()
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		fs := pflag.NewFlagSet(c.name, pflag.ContinueOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", os.Args[0], c.name, c.args, c.usage)
+			fs.PrintDefaults()
+		}
+
+		keys := c.flags(fs)
+		if err := fs.Parse(args); err != nil {
+			if err == pflag.ErrHelp {
+				return nil
+			}
+
+			return err
+		}
+
+		// Flags override config
+		for flag, key := range keys {
+			if err := viper.BindPFlag(key, fs.Lookup(flag)); err != nil {
+				return err
+			}
+
+			flagConfigKeys[key] = true
+		}
+
+		return c.run(fs)
+	}
+
+	printUsage(commands)
+	return fmt.Errorf("unknown command %s", name)
+}
+
+// Print value as indented JSON to stdout
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Start the web server
+func serveCommand(fs *pflag.FlagSet) error {
+	// Initialize search. Fail fast unless degraded mode is enabled
+	// in which case server starts without search index.
+	if err := initSearch(); err != nil {
+		if !cfg().GetBool("degraded_mode") {
+			return fmt.Errorf("Error initializing search: %v", err)
+		}
+
+		log.Errorf("Error initializing search, starting without search index: %v", err)
+	}
+
+	// Open local database and start recording analytics
+	if err := initStore(); err != nil {
+		closeSearch()
+		return err
+	}
+	startAnalytics()
+
+	// Load query time field boosts and ranking version, search cache,
+	// rate limits, trusted proxies and CORS
+	loadFieldBoosts()
+	loadRankingVersion()
+	loadSearchCache()
+	loadRateLimits()
+	loadTrustedProxies()
+	loadCORSConfig()
+	watchConfig()
+
+	// Initialize server and close index and database after its shutdown.
+	// If requests weren't drained, handlers may still be using them so
+	// they're left open and released on exit.
+	serverErr := initServer(cfg().GetString("address"))
+	if serverErr == errShutdownIncomplete {
+		log.Warn("Skipped closing search index and database, requests are still active.")
+		return serverErr
+	}
+
+	stopAnalytics()
+	if err := closeSearch(); err != nil {
+		log.Error("Error closing search index: ", err)
+	}
+	if err := closeStore(); err != nil {
+		log.Error("Error closing database: ", err)
+	}
+
+	return serverErr
+}
+
+// Build, update or show stats of search index
+func indexCommand(fs *pflag.FlagSet) error {
+	args := fs.Args()
+	if len(args) != 1 {
+		return errors.New("index requires one of build, update or stats")
+	}
+
+	switch args[0] {
+	case "build":
+		if err := buildSearchIndex(); err != nil {
+			return err
+		}
+	case "update":
+		if err := updateSearchIndex(); err != nil {
+			return err
+		}
+	case "stats":
+	default:
+		return fmt.Errorf("unknown index command %s", args[0])
+	}
+
+	stats, err := searchIndexStats()
+	if err != nil {
+		return err
+	}
+
+	return printJSON(stats)
+}
+
+// Validate banks data file
+func validateCommand(fs *pflag.FlagSet) error {
+	args := fs.Args()
+	if len(args) != 1 {
+		return errors.New("validate requires a CSV file path")
+	}
+
+	report, err := ingestBanks(nil, args[0], cfg().GetInt("batch_size"), 0)
+	if err != nil {
+		return err
+	}
+
+	if err := printJSON(report); err != nil {
+		return err
+	}
+
+	return report.check(cfg().GetFloat64("validation_max_invalid_ratio"))
+}
+
+// Search the index and print results
+func queryCommand(fs *pflag.FlagSet) error {
+	args := fs.Args()
+	if len(args) == 0 {
+		return errors.New("query requires search text")
+	}
+
+	if err := openSearchIndex(); err != nil {
+		return err
+	}
+	defer bankIndex.Close()
+
+	loadFieldBoosts()
+
+	sortBy, err := fs.GetString("sort")
+	if err != nil {
+		return err
+	}
+
+	sortOrder, err := buildSortOrder(sortBy, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	results, err := querySearch(strings.Join(args, " "), cfg().GetInt("results_size"), 0, sortOrder, nil)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(results)
+}
+
+// Lookup bank by IFSC and print its details
+func lookupCommand(fs *pflag.FlagSet) error {
+	args := fs.Args()
+	if len(args) != 1 {
+		return errors.New("lookup requires an IFSC")
+	}
+
+	if err := openSearchIndex(); err != nil {
+		return err
+	}
+	defer bankIndex.Close()
+
+	hit, err := lookupIFSC(args[0])
+	if err != nil {
+		return err
+	}
+
+	if hit == nil {
+		return fmt.Errorf("IFSC %s not found", args[0])
+	}
+
+	return printJSON(hit.Fields)
+}
+
+// Append bank details to CSV file with IFSC column
+func enrichCommand(fs *pflag.FlagSet) error {
+	args := fs.Args()
+	if len(args) != 1 {
+		return errors.New("enrich requires a CSV file path")
+	}
+
+	column, err := fs.GetString("column")
+	if err != nil {
+		return err
+	}
+
+	output, err := fs.GetString("output")
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	enricher, err := newCSVEnricher(in, column)
+	if err != nil {
+		return err
+	}
+
+	if err := openSearchIndex(); err != nil {
+		return err
+	}
+	defer bankIndex.Close()
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	count, err := enricher.enrich(out, cfg().GetInt("bulk_workers"))
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Enriched %d rows.", count)
+	return nil
+}
+
+// Print effective configuration
+func configCommand(fs *pflag.FlagSet) error {
+	if args := fs.Args(); len(args) != 1 || args[0] != "print" {
+		return errors.New("config requires print")
+	}
+
+	return printJSON(effectiveConfig())
+}
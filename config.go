@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Environment variables prefix. For example BANKR_ADDRESS or BANKR_BOOST_BRANCH
+const envPrefix = "BANKR"
+
+var (
+	// Config keys with secret values which are masked when printed
+	secretConfigKeys = [...]string{"geocode_api_key", "admin_token"}
+	// Config keys which are tables with arbitrary keys
+	mapConfigKeys = [...]string{"std_codes"}
+	// Config keys (or prefixes) which are applied only on restart
+	restartConfigKeys = [...]string{
+		"address",
+		"read_timeout",
+		"write_timeout",
+		"idle_timeout",
+		"search_index_path",
+		"data_path",
+		"xlsx_paths",
+		"banks_list_path",
+		"batch_size",
+		"index_workers",
+		"degraded_mode",
+		"db_path",
+		"analytics_enabled",
+		"std_codes",
+		"validation_",
+	}
+	// Config keys overridden by commandline flags, these are not reloaded
+	flagConfigKeys = make(map[string]bool)
+	// Functions which apply reloaded config, by key prefix
+	configReloaders = []struct {
+		prefix string
+		apply  func()
+	}{
+		{"debug", initLogger},
+		{"log_format", initLogger},
+		{"boost.", loadFieldBoosts},
+		{"boost.", purgeSearchCache},
+		{"boost.", loadRankingVersion},
+		{"results_size", loadRankingVersion},
+		{"cache_size", loadSearchCache},
+		{"rate_limit.", loadRateLimits},
+		{"trusted_proxies", loadTrustedProxies},
+		{"cors.", loadCORSConfig},
+	}
+	// Current config. A reload builds a new config and swaps it in, so a
+	// config is never modified while handlers are reading it.
+	currentConfig atomic.Value
+	// Serializes reloads from SIGHUP and config file changes
+	reloadMutex sync.Mutex
+)
+
+// Get current config, the global viper instance until first reload
+func cfg() *viper.Viper {
+	if v, ok := currentConfig.Load().(*viper.Viper); ok {
+		return v
+	}
+
+	return viper.GetViper()
+}
+
+// Get config file path from --config flag or BANKR_CONFIG env
+// variable. Returns remaining args without the config flag.
+func parseConfigFlag(args []string) (string, []string) {
+	path := os.Getenv(envPrefix + "_CONFIG")
+	rest := []string{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--config" && i+1 < len(args):
+			path = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--config="):
+			path = strings.TrimPrefix(arg, "--config=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return path, rest
+}
+
+// Build config schema from defaults. Type of the default
+// value is the expected type for the key.
+func configSchema(v *viper.Viper) map[string]interface{} {
+	schema := make(map[string]interface{})
+	for _, key := range v.AllKeys() {
+		schema[key] = v.Get(key)
+	}
+
+	return schema
+}
+
+// Check if key is in schema. Tables such as std_codes can have any keys.
+func isKnownConfigKey(schema map[string]interface{}, key string) bool {
+	if _, ok := schema[key]; ok {
+		return true
+	}
+
+	for _, k := range mapConfigKeys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if value can be used as the type of default value
+func checkConfigType(def interface{}, val interface{}) error {
+	var err error
+
+	switch def.(type) {
+	case bool:
+		_, err = cast.ToBoolE(val)
+	case int:
+		if f, ok := val.(float64); ok && f != math.Trunc(f) {
+			return fmt.Errorf("expected integer, got %v", val)
+		}
+		_, err = cast.ToIntE(val)
+	case float64:
+		_, err = cast.ToFloat64E(val)
+	case string:
+		if _, ok := val.(string); !ok {
+			return fmt.Errorf("expected string, got %v", val)
+		}
+	case []string:
+		_, err = cast.ToStringSliceE(val)
+	case time.Duration:
+		_, err = cast.ToDurationE(val)
+	}
+
+	return err
+}
+
+// Check values which depend on each other. Search results size has to be
+// positive and within maximum results size, which has to be within maximum
+// result window so that at least one page can be requested.
+func checkConfigRanges(v *viper.Viper) []string {
+	var errs []string
+
+	size, maxSize, maxWindow := v.GetInt("results_size"), v.GetInt("max_results_size"), v.GetInt("max_result_window")
+	if size < 1 {
+		errs = append(errs, fmt.Sprintf("invalid value for results_size: %d should be at least 1", size))
+	}
+
+	if maxSize < size {
+		errs = append(errs, fmt.Sprintf("invalid value for max_results_size: %d is less than results_size %d", maxSize, size))
+	}
+
+	if maxWindow < maxSize {
+		errs = append(errs, fmt.Sprintf("invalid value for max_result_window: %d is less than max_results_size %d", maxWindow, maxSize))
+	}
+
+	return errs
+}
+
+// Validate config against schema. Unknown keys in config file and environment
+// and values which can't be used as the type of default value are rejected.
+func validateConfig(v *viper.Viper, schema map[string]interface{}) error {
+	var errs []string
+
+	// Unknown keys in config file
+	if path := v.ConfigFileUsed(); path != "" {
+		fileConfig := viper.New()
+		fileConfig.SetConfigFile(path)
+		if err := fileConfig.ReadInConfig(); err != nil {
+			return err
+		}
+
+		for _, key := range fileConfig.AllKeys() {
+			if !isKnownConfigKey(schema, key) {
+				errs = append(errs, fmt.Sprintf("unknown config key %s", key))
+			}
+		}
+	}
+
+	// Unknown environment variables with prefix
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(name, envPrefix+"_") || name == envPrefix+"_CONFIG" {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(name, envPrefix+"_"))
+		known := false
+		for k := range schema {
+			if strings.Replace(k, ".", "_", -1) == key {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			errs = append(errs, fmt.Sprintf("unknown environment variable %s", name))
+		}
+	}
+
+	// Mistyped values
+	for key, def := range schema {
+		if err := checkConfigType(def, v.Get(key)); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value for %s: %v", key, err))
+		}
+	}
+
+	// Values out of range, checked only if they're well typed
+	if len(errs) == 0 {
+		errs = append(errs, checkConfigRanges(v)...)
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
+
+// Get effective configuration with secrets masked
+func effectiveConfig() map[string]interface{} {
+	settings := cfg().AllSettings()
+	for _, key := range secretConfigKeys {
+		if v, ok := settings[key]; ok && v != "" {
+			settings[key] = "********"
+		}
+	}
+
+	return settings
+}
+
+// Check if config key requires restart to apply
+func requiresRestart(key string) bool {
+	for _, k := range restartConfigKeys {
+		if strings.HasPrefix(key, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Format config value for logs with secrets masked
+func formatConfigValue(key string, value interface{}) string {
+	for _, k := range secretConfigKeys {
+		if k == key {
+			return "********"
+		}
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// Reload config file. New config is validated before applying and only
+// settings which are safe to change at runtime are applied.
+func reloadConfig() {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	current := cfg()
+	next := viper.New()
+	if err := loadConfig(next, current.ConfigFileUsed()); err != nil {
+		log.Errorf("Config not reloaded: %v", err)
+		return
+	}
+
+	changed := []string{}
+	for _, key := range next.AllKeys() {
+		if flagConfigKeys[key] {
+			continue
+		}
+
+		oldValue, newValue := current.Get(key), next.Get(key)
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		if requiresRestart(key) {
+			log.Warnf("Config %s changed from %s to %s, restart required to apply.", key,
+				formatConfigValue(key, oldValue), formatConfigValue(key, newValue))
+			continue
+		}
+
+		changed = append(changed, key)
+		log.Infof("Config %s changed from %s to %s", key,
+			formatConfigValue(key, oldValue), formatConfigValue(key, newValue))
+	}
+
+	if len(changed) == 0 {
+		log.Info("Config reloaded, nothing changed.")
+		return
+	}
+
+	// Keep commandline flags and settings which are applied only on restart
+	for _, key := range current.AllKeys() {
+		if flagConfigKeys[key] || requiresRestart(key) {
+			next.Set(key, current.Get(key))
+		}
+	}
+
+	currentConfig.Store(next)
+
+	for _, r := range configReloaders {
+		for _, key := range changed {
+			if strings.HasPrefix(key, r.prefix) {
+				r.apply()
+				break
+			}
+		}
+	}
+}
+
+// Reload config on SIGHUP and when config file changes
+func watchConfig() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		for range sig {
+			log.Info("Received SIGHUP, reloading config.")
+			reloadConfig()
+		}
+	}()
+
+	path := cfg().ConfigFileUsed()
+	if path == "" {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf("Error watching config file: %v", err)
+		return
+	}
+
+	// Watch config directory since editors replace the file on save
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		log.Errorf("Error watching config file: %v", err)
+		watcher.Close()
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events:
+				if filepath.Clean(event.Name) != path ||
+					event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+
+				log.Infof("Config file %s changed, reloading config.", path)
+				reloadConfig()
+			case err := <-watcher.Errors:
+				log.Errorf("Error watching config file: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateConfigRanges(t *testing.T) {
+	cases := []struct {
+		size, maxSize, maxWindow int
+		valid                    bool
+	}{
+		{10, 50, 10000, true},
+		{50, 50, 50, true},
+		{0, 50, 10000, false},
+		{-1, 50, 10000, false},
+		{60, 50, 10000, false},
+		{10, 50, 20, false},
+	}
+
+	for _, c := range cases {
+		v := viper.New()
+		setDefaultConfig(v)
+		schema := configSchema(v)
+
+		v.Set("results_size", c.size)
+		v.Set("max_results_size", c.maxSize)
+		v.Set("max_result_window", c.maxWindow)
+
+		err := validateConfig(v, schema)
+		if valid := err == nil; valid != c.valid {
+			t.Errorf("validateConfig(size=%d, max_size=%d, max_window=%d) = %v, want valid %v",
+				c.size, c.maxSize, c.maxWindow, err, c.valid)
+		}
+	}
+}
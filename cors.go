@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CORS config loaded from config
+type corsConfig struct {
+	origins        map[string]bool
+	anyOrigin      bool
+	methods        string
+	headers        string
+	exposedHeaders string
+	maxAge         string
+}
+
+var (
+	cors   corsConfig
+	corsMu sync.RWMutex
+)
+
+// Load CORS config. Cross origin requests are not allowed if allowed origins is empty.
+func loadCORSConfig() {
+	c := corsConfig{
+		origins:        make(map[string]bool),
+		methods:        strings.Join(cfg().GetStringSlice("cors.allowed_methods"), ", "),
+		headers:        strings.Join(cfg().GetStringSlice("cors.allowed_headers"), ", "),
+		exposedHeaders: strings.Join(cfg().GetStringSlice("cors.exposed_headers"), ", "),
+		maxAge:         strconv.Itoa(int(cfg().GetDuration("cors.max_age").Seconds())),
+	}
+
+	for _, origin := range cfg().GetStringSlice("cors.allowed_origins") {
+		if origin == "*" {
+			c.anyOrigin = true
+		}
+
+		c.origins[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+
+	corsMu.Lock()
+	cors = c
+	corsMu.Unlock()
+}
+
+// Get CORS config
+func getCORSConfig() corsConfig {
+	corsMu.RLock()
+	defer corsMu.RUnlock()
+
+	return cors
+}
+
+// Add CORS headers for allowed origins and respond to preflight requests
+func CORS() Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			c := getCORSConfig()
+			allowed := c.anyOrigin || c.origins[strings.ToLower(origin)]
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if allowed {
+				if c.anyOrigin {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+
+			// Preflight requests are answered without calling the handler
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				if allowed {
+					w.Header().Set("Access-Control-Allow-Methods", c.methods)
+					w.Header().Set("Access-Control-Allow-Headers", c.headers)
+					w.Header().Set("Access-Control-Max-Age", c.maxAge)
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if allowed && c.exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.exposedHeaders)
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Number of rows looked up together while enriching CSV
+const enrichChunkSize = 500
+
+var (
+	// Columns appended to enriched CSV and index fields they're taken from
+	enrichColumns = [...]string{"BANK", "BRANCH", "CITY", "STATE", "MICR", "IFSC_STATUS"}
+	enrichFields  = [...]string{"name", "branch", "city", "state", "MICR"}
+)
+
+// Enriches CSV having an IFSC column with bank details
+type csvEnricher struct {
+	reader *csv.Reader
+	header []string
+	column int
+}
+
+// Create CSV enricher and find IFSC column in header. Column name is case insensitive.
+func newCSVEnricher(in io.Reader, column string) (*csvEnricher, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty.")
+	} else if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return &csvEnricher{reader: r, header: header, column: i}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("CSV doesn't have %s column.", column)
+}
+
+// Write CSV with bank details appended to each row. Rows are read and
+// looked up in chunks so that large files are not loaded into memory.
+// Returns number of rows written.
+func (e *csvEnricher) enrich(out io.Writer, workers int) (int, error) {
+	w := csv.NewWriter(out)
+	if err := w.Write(append(e.header, enrichColumns[:]...)); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		rows, err := e.readChunk()
+		if err != nil {
+			return count, err
+		}
+
+		if len(rows) == 0 {
+			break
+		}
+
+		codes := make([]string, len(rows))
+		for i, row := range rows {
+			if e.column < len(row) {
+				codes[i] = row[e.column]
+			}
+		}
+
+		for i, res := range lookupIFSCs(codes, workers) {
+			if err := w.Write(append(rows[i], enrichValues(res)...)); err != nil {
+				return count, err
+			}
+		}
+
+		count += len(rows)
+
+		// Flush every chunk so that output is streamed
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return count, err
+		}
+	}
+
+	w.Flush()
+	return count, w.Error()
+}
+
+// Read next chunk of rows
+func (e *csvEnricher) readChunk() ([][]string, error) {
+	rows := make([][]string, 0, enrichChunkSize)
+	for len(rows) < enrichChunkSize {
+		row, err := e.reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Get values of enrich columns from IFSC lookup result
+func enrichValues(res IFSCResult) []string {
+	values := make([]string, 0, len(enrichColumns))
+
+	fields, _ := res.Bank.(map[string]interface{})
+	for _, f := range enrichFields {
+		values = append(values, fieldString(fields[f]))
+	}
+
+	return append(values, res.Status)
+}
+
+// Get string value of a stored field. Fields indexed with multiple
+// mappings are returned as a list of values.
+func fieldString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []interface{}:
+		if len(val) > 0 {
+			return fieldString(val[0])
+		}
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
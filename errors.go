@@ -0,0 +1,60 @@
+package main
+
+import "fmt"
+
+// MissingDataError is returned when banks data file doesn't exist
+type MissingDataError struct {
+	Path string
+}
+
+func (e *MissingDataError) Error() string {
+	return fmt.Sprintf("data file %s doesn't exist", e.Path)
+}
+
+// MalformedDataError is returned when banks data file can't be parsed
+type MalformedDataError struct {
+	Path string
+	Err  error
+}
+
+func (e *MalformedDataError) Error() string {
+	return fmt.Sprintf("malformed data file %s: %v", e.Path, e.Err)
+}
+
+// IndexError is returned when search index can't be opened, created or written to
+type IndexError struct {
+	Path string
+	Err  error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("search index %s is unusable, remove it to rebuild: %v", e.Path, e.Err)
+}
+
+// IndexMappingError is returned when search index was built with a different mapping
+type IndexMappingError struct {
+	Path string
+}
+
+func (e *IndexMappingError) Error() string {
+	return fmt.Sprintf("search index %s was built with a different mapping, run `index build` to rebuild it", e.Path)
+}
+
+// IndexLockedError is returned when search index is locked by another process
+type IndexLockedError struct {
+	Path string
+}
+
+func (e *IndexLockedError) Error() string {
+	return fmt.Sprintf("search index %s is locked by another process, stop the server and retry", e.Path)
+}
+
+// StoreError is returned when local database can't be opened or written to
+type StoreError struct {
+	Path string
+	Err  error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("database %s is unusable: %v", e.Path, e.Err)
+}
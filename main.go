@@ -1,66 +1,158 @@
 package main
 
 import (
-	"flag"
 	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
 
-	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	log "github.com/Sirupsen/logrus"
 )
 
-func init() {
-	// Initialize the app configuration
-	initConfig()
+var (
+	// Build version and date injected at build time
+	buildVersion = "unknown"
+	buildDate    = "unknown"
+	// Time when the process started
+	startedAt = time.Now()
+)
 
-	// Initialize logger
-	initLogger()
+// Initializes the app configuration
+func initConfig(path string) error {
+	return loadConfig(viper.GetViper(), path)
 }
 
-// Initializes the app configuration
-func initConfig() {
-	viper.AddConfigPath(".")
-	viper.SetConfigName("config")
+// Load config from given path or config.toml in current directory.
+// Config can be overridden by BANKR_* environment variables and
+// is validated against the defaults.
+func loadConfig(v *viper.Viper, path string) error {
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.AddConfigPath(".")
+		v.SetConfigName("config")
+	}
 
 	// Default configs
-	viper.SetDefault("environment", "debug")
+	setDefaultConfig(v)
+	schema := configSchema(v)
+
+	// Environment variables
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// Default config file is optional, defaults are used without it
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || path != "" {
+			return fmt.Errorf("Error reading config file: %v", err)
+		}
+
+		log.Warn("Config file not found, using defaults.")
+	}
+
+	return validateConfig(v, schema)
+}
+
+// Set default configs
+func setDefaultConfig(v *viper.Viper) {
+	// Enable debug logs
+	v.SetDefault("debug", false)
+	// Log format, text or json
+	v.SetDefault("log_format", "text")
 	// Port to run the app
-	viper.SetDefault("address", "127.0.0.1:3000")
+	v.SetDefault("address", "127.0.0.1:3000")
 	// Bleve search index path
-	viper.SetDefault("search_index_path", "search.index")
+	v.SetDefault("search_index_path", "search.index")
 	// RBI parsed CSV file path
-	viper.SetDefault("data_path", "data.csv")
+	v.SetDefault("data_path", "data.csv")
+	// RBI IFSC/MICR XLSX files imported to data_path when it's missing or outdated
+	v.SetDefault("xlsx_paths", []string{})
 	// List of banks in JSON format
-	viper.SetDefault("banks_list_path", "banks.json")
+	v.SetDefault("banks_list_path", "banks.json")
 	// Default bulk insert batch size
-	viper.SetDefault("batch_size", 100)
-	// Reindex on every run
-	viper.SetDefault("re_index", false)
-	// Only index the data instead of starting the server
-	viper.SetDefault("create_index", false)
+	v.SetDefault("batch_size", 100)
+	// Number of concurrent indexing workers
+	v.SetDefault("index_workers", runtime.NumCPU())
+	// Server timeouts and grace period to drain connections on shutdown
+	v.SetDefault("read_timeout", 10*time.Second)
+	v.SetDefault("write_timeout", 30*time.Second)
+	v.SetDefault("idle_timeout", 120*time.Second)
+	v.SetDefault("shutdown_timeout", 30*time.Second)
+	// Start server without search index if it fails to load
+	v.SetDefault("degraded_mode", false)
 	// Banks db path
-	viper.SetDefault("db_path", "banks.db")
+	v.SetDefault("db_path", "banks.db")
+	// Search analytics, recorded in db. IPs can be anonymized before storing.
+	v.SetDefault("analytics_enabled", true)
+	v.SetDefault("analytics_anonymize_ip", false)
+	// Default report window and number of entries in each report list
+	v.SetDefault("analytics_window", 24*time.Hour)
+	v.SetDefault("analytics_report_size", 20)
+	// Bearer token for admin endpoints, used to create the first admin API key.
+	// Admin endpoints can only be accessed with admin API keys if empty.
+	v.SetDefault("admin_token", "")
+	// Allow search without API key
+	v.SetDefault("api_anonymous_access", true)
+	// Trusted proxy IPs or CIDRs whose X-Forwarded-For header is used for client IP
+	v.SetDefault("trusted_proxies", []string{})
+	// Requests per second and burst per client for each route, zero rate disables limit
+	v.SetDefault("rate_limit.search.rate", 10.0)
+	v.SetDefault("rate_limit.search.burst", 20)
+	v.SetDefault("rate_limit.location.rate", 1.0)
+	v.SetDefault("rate_limit.location.burst", 5)
+	v.SetDefault("rate_limit.lookup.rate", 1.0)
+	v.SetDefault("rate_limit.lookup.burst", 5)
+	v.SetDefault("rate_limit.admin.rate", 5.0)
+	v.SetDefault("rate_limit.admin.burst", 10)
+	// CORS for browser clients on other origins, disabled if no origins are allowed
+	v.SetDefault("cors.allowed_origins", []string{})
+	v.SetDefault("cors.allowed_methods", []string{"GET", "POST", "DELETE", "OPTIONS"})
+	v.SetDefault("cors.allowed_headers", []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID"})
+	v.SetDefault("cors.exposed_headers", []string{"X-Request-ID", "ETag", "Last-Modified", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"})
+	v.SetDefault("cors.max_age", 10*time.Minute)
 	// Geocode config
-	viper.SetDefault("geocode_api_key", "")
-	viper.SetDefault("geocode_api_uri", "")
-
-	// Parse commandline
-	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
-	pflag.Parse()
-
-	err := viper.ReadInConfig()
-	if err != nil { // Handle errors reading the config file
-		panic(fmt.Errorf("Fatal error config file: %s", err))
-	}
+	v.SetDefault("geocode_api_key", "")
+	v.SetDefault("geocode_api_uri", "")
+	// STD codes by city or district for contact normalization
+	v.SetDefault("std_codes", map[string]string{})
+	// Data validation report and maximum ratio of invalid rows
+	// allowed on import (0 disables the check)
+	v.SetDefault("validation_report_path", "validation_report.json")
+	v.SetDefault("validation_report_max_rows", 1000)
+	v.SetDefault("validation_max_invalid_ratio", 0.0)
+	// Number of search responses cached in memory, 0 disables cache
+	v.SetDefault("cache_size", 1000)
+	// Maximum IFSCs in a bulk lookup and number of concurrent lookups
+	v.SetDefault("bulk_max_ifscs", 1000)
+	v.SetDefault("bulk_workers", 8)
+	// Read and write timeout of CSV enrichment requests, which stream large files
+	v.SetDefault("enrich_timeout", 10*time.Minute)
+	// Default and maximum number of search results per page
+	v.SetDefault("results_size", 10)
+	v.SetDefault("max_results_size", 50)
+	// Maximum results reachable with page numbers, deeper pages require search_after
+	v.SetDefault("max_result_window", 10000)
+	// Query time field boosts (branch > city > district > address)
+	v.SetDefault("boost.branch", 4.0)
+	v.SetDefault("boost.city", 3.0)
+	v.SetDefault("boost.district", 2.0)
+	v.SetDefault("boost.address", 1.0)
 }
 
 // Initialize loggers
 func initLogger() {
-	log.SetFormatter(&log.TextFormatter{FullTimestamp: true, ForceColors: true})
+	if cfg().GetString("log_format") == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true, ForceColors: true})
+	}
 
 	// Set log level based on environment
-	if viper.GetBool("debug") {
+	if cfg().GetBool("debug") {
 		log.SetLevel(log.DebugLevel)
 	} else {
 		log.SetLevel(log.InfoLevel)
@@ -68,11 +160,20 @@ func initLogger() {
 }
 
 func main() {
-	log.Debug("Current env : ", viper.GetBool("debug"))
+	// Initialize the app configuration
+	configPath, args := parseConfigFlag(os.Args[1:])
+	if err := initConfig(configPath); err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize logger
+	initLogger()
 
-	// Initialize search
-	initSearch()
+	log.Debug("Current env : ", cfg().GetBool("debug"))
+	log.Infof("Build version: %s, build date: %s", buildVersion, buildDate)
 
-	// Initialize server
-	initServer(viper.GetString("address"))
+	// Run subcommand, defaults to serve
+	if err := runCommand(args); err != nil {
+		log.Fatal(err)
+	}
 }
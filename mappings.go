@@ -11,6 +11,7 @@ import (
 	"github.com/blevesearch/bleve/analysis/token/lowercase"
 	"github.com/blevesearch/bleve/analysis/token/shingle"
 	"github.com/blevesearch/bleve/analysis/token/stop"
+	"github.com/blevesearch/bleve/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/analysis/tokenizer/whitespace"
 	"github.com/blevesearch/bleve/analysis/tokenmap"
 	"github.com/blevesearch/bleve/mapping"
@@ -18,6 +19,21 @@ import (
 
 const textFieldAnalyzer = "en"
 
+// Analyzer for sortable keyword fields
+const sortFieldAnalyzer = "sort_analyzer"
+
+// Keyword field mapping indexed under given name and used only for sorting
+func newSortFieldMapping(name string) *mapping.FieldMapping {
+	sortMapping := bleve.NewTextFieldMapping()
+	sortMapping.Name = name
+	sortMapping.Analyzer = sortFieldAnalyzer
+	sortMapping.Store = false
+	sortMapping.IncludeInAll = false
+	sortMapping.IncludeTermVectors = false
+
+	return sortMapping
+}
+
 func buildIndexMapping() (mapping.IndexMapping, error) {
 	bankMapping := bleve.NewDocumentMapping()
 
@@ -30,6 +46,8 @@ func buildIndexMapping() (mapping.IndexMapping, error) {
 	bankMapping.AddFieldMappingsAt("city", standardMapping)
 	bankMapping.AddFieldMappingsAt("district", standardMapping)
 	bankMapping.AddFieldMappingsAt("state", standardMapping)
+	bankMapping.AddFieldMappingsAt("landmark", standardMapping)
+	bankMapping.AddFieldMappingsAt("locality", standardMapping)
 
 	standardMappingNoFilter := bleve.NewTextFieldMapping()
 	standardMappingNoFilter.Analyzer = "standard_analyzer_nofilter"
@@ -46,6 +64,22 @@ func buildIndexMapping() (mapping.IndexMapping, error) {
 	bankMapping.AddFieldMappingsAt("IFSC", keywordFieldMapping)
 	bankMapping.AddFieldMappingsAt("MICR", keywordFieldMapping)
 	bankMapping.AddFieldMappingsAt("abbreviation", keywordFieldMapping)
+	bankMapping.AddFieldMappingsAt("pincode", keywordFieldMapping)
+	bankMapping.AddFieldMappingsAt("contacts", keywordFieldMapping)
+
+	// Stored only fields
+	storedFieldMapping := bleve.NewTextFieldMapping()
+	storedFieldMapping.Index = false
+	storedFieldMapping.IncludeInAll = false
+	bankMapping.AddFieldMappingsAt("contact_raw", storedFieldMapping)
+
+	// Sortable keyword fields
+	bankMapping.AddFieldMappingsAt("name", newSortFieldMapping("name_sort"))
+	bankMapping.AddFieldMappingsAt("branch", newSortFieldMapping("branch_sort"))
+	bankMapping.AddFieldMappingsAt("city", newSortFieldMapping("city_sort"))
+
+	// Bank location used for distance sort
+	bankMapping.AddFieldMappingsAt("location", bleve.NewGeoPointFieldMapping())
 
 	indexMapping := bleve.NewIndexMapping()
 	indexMapping.AddDocumentMapping("_default", bankMapping)
@@ -174,5 +208,18 @@ func buildIndexMapping() (mapping.IndexMapping, error) {
 		return nil, err
 	}
 
+	// Whole field value as a single lowercased token for sorting
+	err = indexMapping.AddCustomAnalyzer(sortFieldAnalyzer,
+		map[string]interface{}{
+			"type":      custom.Name,
+			"tokenizer": single.Name,
+			"token_filters": []interface{}{
+				lowercase.Name,
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
+
 	return indexMapping, nil
 }
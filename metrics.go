@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "bankr"
+
+// HTTP methods recorded as is in metrics, others are recorded as OTHER
+// so that clients can't create unbounded number of label values.
+var metricsMethods = [...]string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+var (
+	// HTTP requests by route, method and response status
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// Search results and query time
+	searchResultsCount = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "search_results",
+		Help:      "Number of results matched by search queries.",
+		Buckets:   []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000, 10000},
+	})
+	searchZeroResultsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "search_zero_results_total",
+		Help:      "Total number of search queries without any results.",
+	})
+	searchQueryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "search_query_duration_seconds",
+		Help:      "Search index query time in seconds.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	})
+
+	// Search response cache hits and misses
+	searchCacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "search_cache_hits_total",
+		Help:      "Total number of searches served from cache.",
+	})
+	searchCacheMissesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "search_cache_misses_total",
+		Help:      "Total number of searches not found in cache.",
+	})
+
+	// Geocode upstream latency and errors
+	geocodeRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "geocode_request_duration_seconds",
+		Help:      "Geocode upstream request latency in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	geocodeErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "geocode_errors_total",
+		Help:      "Total number of failed geocode upstream requests.",
+	})
+
+	// Documents in search index, zero if index is not loaded
+	indexDocCount = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "index_documents",
+		Help:      "Number of documents in search index.",
+	}, func() float64 {
+		if bankIndex == nil {
+			return 0
+		}
+
+		count, err := bankIndex.DocCount()
+		if err != nil {
+			return 0
+		}
+
+		return float64(count)
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestsTotal,
+		httpRequestDuration,
+		searchResultsCount,
+		searchZeroResultsTotal,
+		searchQueryDuration,
+		searchCacheHitsTotal,
+		searchCacheMissesTotal,
+		geocodeRequestDuration,
+		geocodeErrorsTotal,
+		indexDocCount,
+	)
+}
+
+// Record request count and latency of a route
+func Metrics(route string) Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			startTime := time.Now()
+			rw := newResponseWriter(w)
+			h.ServeHTTP(rw, r)
+
+			status := strconv.Itoa(rw.status)
+			method := metricsMethod(r.Method)
+			httpRequestsTotal.WithLabelValues(route, method, status).Inc()
+			httpRequestDuration.WithLabelValues(route, method, status).Observe(time.Since(startTime).Seconds())
+		})
+	}
+}
+
+// Get method label of request method
+func metricsMethod(method string) string {
+	for _, m := range metricsMethods {
+		if method == m {
+			return m
+		}
+	}
+
+	return "OTHER"
+}
+
+// Record search results count and query time. Query time isn't recorded
+// for responses served from cache as index wasn't queried.
+func observeSearch(total uint64, took time.Duration, cached bool) {
+	searchResultsCount.Observe(float64(total))
+	if total == 0 {
+		searchZeroResultsTotal.Inc()
+	}
+
+	if !cached {
+		searchQueryDuration.Observe(took.Seconds())
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/viper"
+)
+
+// Get current value of a counter
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("Error while reading counter: %v", err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func TestMetricsMethod(t *testing.T) {
+	cases := map[string]string{
+		"GET":     "GET",
+		"POST":    "POST",
+		"OPTIONS": "OPTIONS",
+		"get":     "OTHER",
+		"PURGE":   "OTHER",
+		"":        "OTHER",
+	}
+
+	for method, want := range cases {
+		if got := metricsMethod(method); got != want {
+			t.Errorf("metricsMethod(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+// Geocode API errors are counted and responded with bad gateway
+func TestGeocodeAddressHandlerErrors(t *testing.T) {
+	geocodeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer geocodeAPI.Close()
+
+	uri := viper.GetString("geocode_api_uri")
+	defer viper.Set("geocode_api_uri", uri)
+	viper.Set("geocode_api_uri", geocodeAPI.URL)
+
+	before := counterValue(t, geocodeErrorsTotal)
+
+	rec := httptest.NewRecorder()
+	getGeocodeAddressHandler(rec, httptest.NewRequest("GET", "/api/location?latitude=12.93&longitude=77.58", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+
+	if errors := counterValue(t, geocodeErrorsTotal) - before; errors != 1 {
+		t.Errorf("Geocode errors increased by %v, want 1", errors)
+	}
+}
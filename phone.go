@@ -0,0 +1,124 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// India country calling code
+const countryCode = "91"
+
+var (
+	// Separators between multiple numbers in contact field
+	contactSplitRegexp = regexp.MustCompile(`[,;/&]|\bOR\b`)
+	// Number written with STD code. For example 080-22261717 or (080) 22261717
+	stdNumberRegexp = regexp.MustCompile(`^\(?0?([1-9][0-9]{1,3})\)?[\s\-]+([0-9]{5,8})$`)
+	// Non digit characters
+	nonDigitRegexp = regexp.MustCompile(`[^0-9]`)
+	// STD codes (without trunk prefix 0) of cities and districts used
+	// when contact number doesn't have one. Can be extended with std_codes config.
+	stdCodes = map[string]string{
+		"AHMEDABAD":          "79",
+		"BANGALORE":          "80",
+		"BANGALORE URBAN":    "80",
+		"BENGALURU":          "80",
+		"BHOPAL":             "755",
+		"CHANDIGARH":         "172",
+		"CHENNAI":            "44",
+		"COIMBATORE":         "422",
+		"DELHI":              "11",
+		"NEW DELHI":          "11",
+		"HYDERABAD":          "40",
+		"INDORE":             "731",
+		"JAIPUR":             "141",
+		"KOCHI":              "484",
+		"ERNAKULAM":          "484",
+		"KOLKATA":            "33",
+		"LUCKNOW":            "522",
+		"MANGALORE":          "824",
+		"DAKSHIN KANNADA":    "824",
+		"MUMBAI":             "22",
+		"MYSORE":             "821",
+		"NAGPUR":             "712",
+		"PATNA":              "612",
+		"PUNE":               "20",
+		"SURAT":              "261",
+		"THIRUVANANTHAPURAM": "471",
+		"VADODARA":           "265",
+		"VISAKHAPATNAM":      "891",
+	}
+)
+
+// Get STD code for city or district. Codes from config take precedence.
+func lookupSTDCode(city string, district string) string {
+	configCodes := cfg().GetStringMapString("std_codes")
+	for _, place := range []string{city, district} {
+		place = strings.ToUpper(strings.TrimSpace(place))
+		if place == "" {
+			continue
+		}
+
+		if code, ok := configCodes[strings.ToLower(place)]; ok {
+			return strings.TrimPrefix(code, "0")
+		}
+
+		if code, ok := stdCodes[place]; ok {
+			return code
+		}
+	}
+
+	return ""
+}
+
+// Normalize a single phone number to E.164 format. Returns
+// empty string if number can't be normalized.
+func normalizePhone(number string, stdCode string) string {
+	number = strings.TrimSpace(number)
+
+	// Number with explicit STD code
+	if m := stdNumberRegexp.FindStringSubmatch(number); m != nil {
+		stdCode = m[1]
+		number = m[2]
+	}
+
+	digits := nonDigitRegexp.ReplaceAllString(number, "")
+	switch {
+	// Number with country code
+	case len(digits) == 12 && strings.HasPrefix(digits, countryCode):
+		return "+" + digits
+	// Mobile number
+	case len(digits) == 10 && strings.IndexAny(digits[:1], "6789") == 0:
+		return "+" + countryCode + digits
+	// Landline number with trunk prefix and STD code
+	case len(digits) == 11 && digits[0] == '0':
+		return "+" + countryCode + digits[1:]
+	// Local landline number, prefix STD code
+	case len(digits) >= 6 && len(digits) <= 8 && stdCode != "" && len(stdCode)+len(digits) == 10:
+		return "+" + countryCode + stdCode + digits
+	}
+
+	return ""
+}
+
+// Normalize contact field which may have multiple numbers
+// to a list of unique E.164 numbers
+func normalizeContacts(contact string, city string, district string) []string {
+	contacts := []string{}
+	if contact == "" {
+		return contacts
+	}
+
+	stdCode := lookupSTDCode(city, district)
+	seen := make(map[string]bool)
+	for _, number := range contactSplitRegexp.Split(strings.ToUpper(contact), -1) {
+		phone := normalizePhone(number, stdCode)
+		if phone == "" || seen[phone] {
+			continue
+		}
+
+		seen[phone] = true
+		contacts = append(contacts, phone)
+	}
+
+	return contacts
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	cases := []struct {
+		number  string
+		stdCode string
+		want    string
+	}{
+		{"080-22261717", "", "+918022261717"},
+		{"(080) 22261717", "", "+918022261717"},
+		{"(080) 2226 1717", "", "+918022261717"},
+		{"22261717", "80", "+918022261717"},
+		{"22261717", "", ""},
+		{"9845012345", "", "+919845012345"},
+		{"+91 98450 12345", "", "+919845012345"},
+		{"08022261717", "", "+918022261717"},
+		{"NA", "80", ""},
+		{"12345", "80", ""},
+		{"", "80", ""},
+	}
+
+	for _, c := range cases {
+		if got := normalizePhone(c.number, c.stdCode); got != c.want {
+			t.Errorf("normalizePhone(%q, %q) = %q, want %q", c.number, c.stdCode, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeContacts(t *testing.T) {
+	cases := []struct {
+		contact string
+		city    string
+		want    []string
+	}{
+		{"(080) 2226 1717 / 9845012345", "BANGALORE", []string{"+918022261717", "+919845012345"}},
+		{"080-22261717, 22261717", "BANGALORE", []string{"+918022261717"}},
+		{"22261717 OR 22261718", "BANGALORE", []string{"+918022261717", "+918022261718"}},
+		// Float contacts are converted to integers before normalization
+		{cleanFloat("2.2261717E7"), "BANGALORE", []string{"+918022261717"}},
+		{"22261717", "UNKNOWN CITY", []string{}},
+		{"NA", "BANGALORE", []string{}},
+		{"", "BANGALORE", []string{}},
+	}
+
+	for _, c := range cases {
+		if got := normalizeContacts(c.contact, c.city, ""); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("normalizeContacts(%q, %q) = %v, want %v", c.contact, c.city, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Interval to remove idle client buckets
+const rateLimitSweepInterval = time.Minute
+
+var (
+	// Routes with separate rate limits, configured as rate_limit.<route>
+	rateLimitRoutes = [...]string{"search", "location", "lookup", "admin"}
+	// Rate limiters by route
+	rateLimiters   = make(map[string]*rateLimiter)
+	rateLimitersMu sync.RWMutex
+	// Proxies whose X-Forwarded-For header is trusted for client IP
+	trustedProxies   []*net.IPNet
+	trustedProxiesMu sync.RWMutex
+)
+
+// Token bucket of a client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Token bucket rate limiter. Each client gets a bucket of burst tokens
+// which is refilled at rate tokens per second.
+type rateLimiter struct {
+	sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take a token from client bucket. Returns whether request is allowed,
+// bucket size, remaining tokens and time until bucket is full or, if
+// request is not allowed, time until next token is available.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, int, int, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill tokens since last request
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, l.burst, 0, l.wait(1 - b.tokens)
+	}
+
+	b.tokens--
+	return true, l.burst, int(b.tokens), l.wait(float64(l.burst) - b.tokens)
+}
+
+// Time to refill given number of tokens
+func (l *rateLimiter) wait(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// Remove buckets which would be full by now
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+
+	full := l.wait(float64(l.burst))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// Load rate limits of routes from config. Buckets of existing
+// limiters are kept so that clients don't get a fresh burst.
+func loadRateLimits() {
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+
+	for _, route := range rateLimitRoutes {
+		rate := cfg().GetFloat64("rate_limit." + route + ".rate")
+		burst := cfg().GetInt("rate_limit." + route + ".burst")
+
+		// Zero rate disables limit for route
+		if rate <= 0 {
+			delete(rateLimiters, route)
+			continue
+		}
+
+		if burst < 1 {
+			burst = 1
+		}
+
+		l, ok := rateLimiters[route]
+		if !ok {
+			rateLimiters[route] = newRateLimiter(rate, burst)
+			continue
+		}
+
+		l.Lock()
+		l.rate, l.burst = rate, burst
+		l.Unlock()
+	}
+}
+
+// Get rate limiter of route, nil if route is not limited
+func getRateLimiter(route string) *rateLimiter {
+	rateLimitersMu.RLock()
+	defer rateLimitersMu.RUnlock()
+
+	return rateLimiters[route]
+}
+
+// Load trusted proxy IPs and CIDRs from config
+func loadTrustedProxies() {
+	proxies := []*net.IPNet{}
+	for _, p := range cfg().GetStringSlice("trusted_proxies") {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+
+		_, cidr, err := net.ParseCIDR(p)
+		if err != nil {
+			log.Errorf("Invalid trusted proxy %s: %v", p, err)
+			continue
+		}
+
+		proxies = append(proxies, cidr)
+	}
+
+	trustedProxiesMu.Lock()
+	trustedProxies = proxies
+	trustedProxiesMu.Unlock()
+}
+
+// Check if IP is a trusted proxy
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+
+	for _, cidr := range trustedProxies {
+		if cidr.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rate limit requests to a route by client IP, and also by API key for
+// requests with one. Key is charged only if IP is within limit since keys
+// aren't validated yet, so clients can't get fresh buckets with fake keys.
+// Runs before authentication so rejected requests don't count against API
+// key quota. Sets RateLimit-* headers and Retry-After when limit is exceeded.
+func RateLimit(route string) Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := getRateLimiter(route)
+			if l == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+			ok, limit, remaining, reset := l.allow("ip:"+clientIP(r), now)
+			if secret := r.Header.Get(apiKeyHeader); ok && secret != "" {
+				var keyRemaining int
+				var keyReset time.Duration
+				ok, limit, keyRemaining, keyReset = l.allow("key:"+hashAPIKey(secret), now)
+				if !ok || keyRemaining < remaining {
+					remaining, reset = keyRemaining, keyReset
+				}
+			}
+
+			resetSeconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", resetSeconds)
+
+			if !ok {
+				w.Header().Set("Retry-After", resetSeconds)
+				writeJSONResponse(w, DefaultResponse{"Too many requests. Please retry later."}, http.StatusTooManyRequests)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Send requests to rate limited handler and return number of allowed requests
+func allowedRequests(h http.Handler, n int, ip func(int) string, key func(int) string) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest("GET", "/api/search", nil)
+		req.RemoteAddr = ip(i) + ":1234"
+		if k := key(i); k != "" {
+			req.Header.Set(apiKeyHeader, k)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			allowed++
+		}
+	}
+
+	return allowed
+}
+
+func TestRateLimit(t *testing.T) {
+	// Slow refill so that no tokens are added during the test
+	rate := viper.GetFloat64("rate_limit.search.rate")
+	defer func() {
+		viper.Set("rate_limit.search.rate", rate)
+		loadRateLimits()
+	}()
+	viper.Set("rate_limit.search.rate", 0.001)
+	loadRateLimits()
+
+	burst := getRateLimiter("search").burst
+	h := Adapt(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), RateLimit("search"))
+
+	cases := []struct {
+		name string
+		ip   func(int) string
+		key  func(int) string
+	}{
+		{"anonymous", func(int) string { return "192.0.2.1" }, func(int) string { return "" }},
+		{"fake key per request", func(int) string { return "192.0.2.2" }, func(i int) string { return fmt.Sprintf("fake-%d", i) }},
+		{"key from many IPs", func(i int) string { return fmt.Sprintf("198.51.100.%d", i) }, func(int) string { return "shared-key" }},
+	}
+
+	for _, c := range cases {
+		if got := allowedRequests(h, burst*2, c.ip, c.key); got != burst {
+			t.Errorf("%s: %d of %d requests allowed, want %d", c.name, got, burst*2, burst)
+		}
+	}
+}
@@ -1,37 +1,84 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/boltdb/bolt"
 	"github.com/gocarina/gocsv"
-	"github.com/spf13/viper"
 )
 
+// Time to wait for search index lock held by another process
+const indexLockTimeout = time.Second
+
 var (
 	bankIndex bleve.Index
+	// Whether search index has bank locations required for distance sort
+	indexHasLocations bool
 	// List banks and its abbreviation
 	banksList []BanksList
+	// Modification time of loaded banks data file
+	dataReleaseDate time.Time
 	// List of words excluded from bank name, address and other fields
 	excludedWords = [...]string{"of", "bank", "and", "limited", "ltd"}
+	// Fields boosted at query time, in decreasing order of relevance
+	boostedFields = [...]string{"branch", "city", "district", "address"}
+	// Per field boosts loaded from config
+	fieldBoosts   map[string]float64
+	fieldBoostsMu sync.RWMutex
+	// Sort options and its sortable keyword fields
+	sortFields = map[string]string{
+		"bank":   "name_sort",
+		"branch": "branch_sort",
+		"city":   "city_sort",
+	}
+	// Stored fields which are lists. Index returns a list with single
+	// value as the value itself.
+	listFields = [...]string{"contacts"}
+)
+
+// Sort options which doesn't map to a field
+const (
+	sortScore    = "score"
+	sortDistance = "distance"
 )
 
 // Bank structure
 type Bank struct {
-	Name         string `json:"name" csv:"BANK"`
-	IFSC         string `json:"IFSC" csv:"IFSC"`
-	MICR         string `json:"MICR" csv:"MICR"`
-	Branch       string `json:"branch" csv:"BRANCH"`
-	Address      string `json:"address" csv:"ADDRESS"`
-	Contact      string `json:"contact" csv:"CONTACT"`
-	City         string `json:"city" csv:"CITY"`
-	District     string `json:"district" csv:"DISTRICT"`
-	State        string `json:"state" csv:"STATE"`
-	Abbreviation string `json:"abbreviation" csv:"ABBREVIATION"`
+	Name         string    `json:"name" csv:"BANK"`
+	IFSC         string    `json:"IFSC" csv:"IFSC"`
+	MICR         string    `json:"MICR" csv:"MICR"`
+	Branch       string    `json:"branch" csv:"BRANCH"`
+	Address      string    `json:"address" csv:"ADDRESS"`
+	Contact      string    `json:"contact" csv:"CONTACT"`
+	ContactRaw   string    `json:"contact_raw,omitempty" csv:"-"`
+	Contacts     []string  `json:"contacts" csv:"-"`
+	City         string    `json:"city" csv:"CITY"`
+	District     string    `json:"district" csv:"DISTRICT"`
+	State        string    `json:"state" csv:"STATE"`
+	Abbreviation string    `json:"abbreviation" csv:"ABBREVIATION"`
+	Pincode      string    `json:"pincode" csv:"PINCODE"`
+	Landmark     string    `json:"landmark,omitempty" csv:"LANDMARK"`
+	Locality     string    `json:"locality,omitempty" csv:"LOCALITY"`
+	Latitude     float64   `json:"latitude,omitempty" csv:"LATITUDE"`
+	Longitude    float64   `json:"longitude,omitempty" csv:"LONGITUDE"`
+	Location     *GeoPoint `json:"location,omitempty" csv:"-"`
+}
+
+// GeoPoint is a bank location indexed as geopoint for distance sort
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
 }
 
 // BanksList : List of banks
@@ -40,49 +87,381 @@ type BanksList struct {
 	Name         string `json:"name"`
 }
 
-// Initialze bleve search index for banks data
+// Initialze bleve search index for banks data. Search index
+// is set only if index and banks data are loaded successfully.
 func initSearch() error {
-	var err error
+	dataPath := cfg().GetString("data_path")
+	indexPath := cfg().GetString("search_index_path")
 
-	dataPath := viper.GetString("data_path")
-	batchSize := viper.GetInt("batch_size")
-	indexPath := viper.GetString("search_index_path")
+	imported, err := prepareBanksData(dataPath)
+	if err != nil {
+		return err
+	}
 
-	bankIndex, err = bleve.Open(indexPath)
+	if _, err := os.Stat(indexPath); err == nil && imported {
+		log.Warnf("Data file updated but index %s already exists. Remove it to reindex.", indexPath)
+	}
+
+	// Newly created index which has to be populated with banks data
+	var newIndex bleve.Index
+
+	if err := checkIndexLock(indexPath); err != nil {
+		return err
+	}
+
+	index, err := bleve.Open(indexPath)
 
 	// Create a new search index if index doesn't exist
 	if err == bleve.ErrorIndexPathDoesNotExist {
 		log.Infof("Creating new search index in path %s", indexPath)
 
-		// Check if data file is available else don't create index.
-		if _, err = os.Stat(dataPath); os.IsNotExist(err) {
-			log.Errorf("Data path %s doesn't exist.", dataPath)
+		// Populate banks data and index it
+		index, err = createSearchIndex(indexPath)
+		if err != nil {
+			return &IndexError{Path: indexPath, Err: err}
+		}
+
+		newIndex = index
+	} else if err != nil {
+		return &IndexError{Path: indexPath, Err: err}
+	} else {
+		log.Infof("Opening existing index in path %s", indexPath)
+	}
+
+	// Load banks list to be used for querying and
+	// index banks data if the index is newly created
+	if err := loadBanks(newIndex, dataPath); err != nil {
+		index.Close()
+
+		// Remove partially built index so that its rebuilt on next run
+		if newIndex != nil {
+			os.RemoveAll(indexPath)
+		}
+
+		return err
+	}
+
+	setSearchIndex(index)
+	return nil
+}
+
+// Import RBI XLSX files if data file is missing or outdated and check if
+// data file is available, its required for banks list and indexing.
+// Returns true if data file was imported.
+func prepareBanksData(dataPath string) (bool, error) {
+	imported := false
+	xlsxPaths := cfg().GetStringSlice("xlsx_paths")
+	if len(xlsxPaths) > 0 && isDataStale(dataPath, xlsxPaths) {
+		log.Infof("Importing RBI XLSX files to %s", dataPath)
+		if err := importXLSX(xlsxPaths, dataPath); err != nil {
+			return false, &MalformedDataError{Path: dataPath, Err: err}
+		}
+
+		imported = true
+	}
+
+	if _, err := os.Stat(dataPath); os.IsNotExist(err) {
+		return false, &MissingDataError{Path: dataPath}
+	}
+
+	return imported, nil
+}
+
+// Load banks list and index banks data if index is not nil. Validation
+// report is written and checked against maximum invalid ratio.
+func loadBanks(index bleve.Index, dataPath string) error {
+	report, err := ingestBanks(index, dataPath, cfg().GetInt("batch_size"), cfg().GetInt("index_workers"))
+	if err != nil {
+		return err
+	}
+
+	if reportPath := cfg().GetString("validation_report_path"); reportPath != "" {
+		if err := report.write(reportPath); err != nil {
+			log.Error("Error while writing validation report: ", err)
+		}
+	}
+
+	if err := report.check(cfg().GetFloat64("validation_max_invalid_ratio")); err != nil {
+		return &MalformedDataError{Path: dataPath, Err: err}
+	}
+
+	return nil
+}
+
+// Build a new search index from banks data. Index is built next to the
+// index path and replaces the existing index only if its built successfully,
+// so a failed build leaves the existing index untouched.
+func buildSearchIndex() error {
+	dataPath := cfg().GetString("data_path")
+	indexPath := filepath.Clean(cfg().GetString("search_index_path"))
+	buildPath := indexPath + ".build"
+	oldPath := indexPath + ".old"
+
+	if _, err := prepareBanksData(dataPath); err != nil {
+		return err
+	}
+
+	// Fail before building if existing index is in use
+	if err := checkIndexLock(indexPath); err != nil {
+		return err
+	}
+
+	// Remove leftovers of an interrupted build
+	if err := os.RemoveAll(buildPath); err != nil {
+		return err
+	}
+
+	log.Infof("Building new search index in path %s", buildPath)
+	index, err := createSearchIndex(buildPath)
+	if err != nil {
+		return &IndexError{Path: buildPath, Err: err}
+	}
+
+	err = loadBanks(index, dataPath)
+	if cerr := index.Close(); err == nil && cerr != nil {
+		err = &IndexError{Path: buildPath, Err: cerr}
+	}
+
+	if err != nil {
+		os.RemoveAll(buildPath)
+		return err
+	}
+
+	// Swap new index in place of existing index
+	if _, err := os.Stat(indexPath); err == nil {
+		if err := os.RemoveAll(oldPath); err != nil {
 			return err
 		}
 
-		// Populate banks data and index it
-		bankIndex, err = createSearchIndex(indexPath)
-		if err != nil {
-			log.Error("Error while creating index", err)
+		if err := os.Rename(indexPath, oldPath); err != nil {
 			return err
 		}
+	}
+
+	if err := os.Rename(buildPath, indexPath); err != nil {
+		// Restore existing index
+		os.Rename(oldPath, indexPath)
+		return err
+	}
+
+	log.Infof("Replaced search index in path %s", indexPath)
+	return os.RemoveAll(oldPath)
+}
+
+// Check if search index at path is locked by another process, such as a
+// running server. Returns IndexLockedError if lock isn't released within
+// indexLockTimeout, bleve would otherwise wait for the lock forever.
+func checkIndexLock(path string) error {
+	storePath := filepath.Join(path, "store")
+	if info, err := os.Stat(storePath); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
 
-		// Index banks data
-		indexBank(bankIndex, dataPath, batchSize)
+	db, err := bolt.Open(storePath, 0600, &bolt.Options{Timeout: indexLockTimeout, ReadOnly: true})
+	if err == bolt.ErrTimeout {
+		return &IndexLockedError{Path: path}
 	} else if err != nil {
-		log.Error("Error while opening index: ", err)
+		return &IndexError{Path: path, Err: err}
+	}
+
+	return db.Close()
+}
+
+// Check if search index was built with current index mapping. Returns
+// IndexMappingError if stored mapping differs, for example when index
+// was built before fields were added to mapping.
+func checkIndexMapping(index bleve.Index, path string) error {
+	indexMapping, err := buildIndexMapping()
+	if err != nil {
 		return err
-	} else {
-		log.Infof("Opening existing index in path %s", indexPath)
 	}
 
-	// init banks list to be used for querying
-	log.Info("Loading banks list.")
-	loadBanksList(dataPath)
+	current, err := json.Marshal(indexMapping)
+	if err != nil {
+		return err
+	}
+
+	stored, err := json.Marshal(index.Mapping())
+	if err != nil {
+		return &IndexError{Path: path, Err: err}
+	}
+
+	if !bytes.Equal(current, stored) {
+		return &IndexMappingError{Path: path}
+	}
 
 	return nil
 }
 
+// Close search index if its open
+func closeSearch() error {
+	if bankIndex == nil {
+		return nil
+	}
+
+	log.Info("Closing search index.")
+	err := bankIndex.Close()
+	setSearchIndex(nil)
+	return err
+}
+
+// Set search index used for querying. Cached
+// search results of previous index are purged.
+func setSearchIndex(index bleve.Index) {
+	indexHasLocations = index != nil && hasLocations(index)
+	bankIndex = index
+	purgeSearchCache()
+}
+
+// Check if any bank in index has location. Locations are indexed only
+// if banks data has LATITUDE and LONGITUDE columns, RBI data doesn't.
+func hasLocations(index bleve.Index) bool {
+	gquery := bleve.NewGeoBoundingBoxQuery(-180, 90, 180, -90)
+	gquery.SetField("location")
+
+	searchRequest := bleve.NewSearchRequest(gquery)
+	searchRequest.Size = 0
+
+	results, err := index.Search(searchRequest)
+	if err != nil {
+		log.Error("Error while checking bank locations in index: ", err)
+		return false
+	}
+
+	return results.Total > 0
+}
+
+// Open existing search index and load banks list for querying
+func openSearchIndex() error {
+	dataPath := cfg().GetString("data_path")
+	indexPath := cfg().GetString("search_index_path")
+
+	if err := checkIndexLock(indexPath); err != nil {
+		return err
+	}
+
+	index, err := bleve.Open(indexPath)
+	if err != nil {
+		return &IndexError{Path: indexPath, Err: err}
+	}
+
+	if _, err := ingestBanks(nil, dataPath, cfg().GetInt("batch_size"), 0); err != nil {
+		index.Close()
+		return err
+	}
+
+	setSearchIndex(index)
+	return nil
+}
+
+// Update existing search index with banks data. Banks are upserted by
+// IFSC and documents which are not in the data anymore are deleted.
+func updateSearchIndex() error {
+	dataPath := cfg().GetString("data_path")
+	indexPath := cfg().GetString("search_index_path")
+
+	if err := checkIndexLock(indexPath); err != nil {
+		return err
+	}
+
+	index, err := bleve.Open(indexPath)
+	if err != nil {
+		return &IndexError{Path: indexPath, Err: err}
+	}
+	defer index.Close()
+
+	// Documents are indexed with the mapping stored in index, so fields
+	// added to mapping since index was built wouldn't be indexed
+	if err := checkIndexMapping(index, indexPath); err != nil {
+		return err
+	}
+
+	report, err := ingestBanks(index, dataPath, cfg().GetInt("batch_size"), cfg().GetInt("index_workers"))
+	if err != nil {
+		return err
+	}
+
+	if err := report.check(cfg().GetFloat64("validation_max_invalid_ratio")); err != nil {
+		return &MalformedDataError{Path: dataPath, Err: err}
+	}
+
+	// Find and delete stale documents
+	count, err := index.DocCount()
+	if err != nil {
+		return &IndexError{Path: indexPath, Err: err}
+	}
+
+	searchRequest := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	searchRequest.Size = int(count)
+	results, err := index.Search(searchRequest)
+	if err != nil {
+		return &IndexError{Path: indexPath, Err: err}
+	}
+
+	batch := index.NewBatch()
+	for _, hit := range results.Hits {
+		if !report.hasIFSC(hit.ID) {
+			batch.Delete(hit.ID)
+		}
+	}
+
+	if batch.Size() > 0 {
+		log.Infof("Deleting %d stale documents.", batch.Size())
+		if err := index.Batch(batch); err != nil {
+			return &IndexError{Path: indexPath, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// Get search index document count and stats
+func searchIndexStats() (map[string]interface{}, error) {
+	indexPath := cfg().GetString("search_index_path")
+
+	if err := checkIndexLock(indexPath); err != nil {
+		return nil, err
+	}
+
+	index, err := bleve.Open(indexPath)
+	if err != nil {
+		return nil, &IndexError{Path: indexPath, Err: err}
+	}
+	defer index.Close()
+
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, &IndexError{Path: indexPath, Err: err}
+	}
+
+	return map[string]interface{}{
+		"path":      indexPath,
+		"doc_count": count,
+		"stats":     index.StatsMap(),
+	}, nil
+}
+
+// Lookup bank by IFSC. Returns nil if IFSC is not found.
+func lookupIFSC(ifsc string) (*search.DocumentMatch, error) {
+	tquery := bleve.NewTermQuery(strings.ToUpper(strings.TrimSpace(ifsc)))
+	tquery.SetField("IFSC")
+
+	searchRequest := bleve.NewSearchRequest(tquery)
+	searchRequest.Fields = []string{"*"}
+	searchRequest.Size = 1
+
+	results, err := searchBanks(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits) == 0 {
+		return nil, nil
+	}
+
+	return results.Hits[0], nil
+}
+
 // Import banks data and index it
 func createSearchIndex(path string) (index bleve.Index, err error) {
 	// Build a index mapping for banks structure
@@ -100,100 +479,207 @@ func createSearchIndex(path string) (index bleve.Index, err error) {
 	return index, nil
 }
 
-// Load banks to map
-func loadBanksList(dataPath string) error {
-	banskData, err := os.OpenFile(dataPath, os.O_RDONLY, os.ModePerm)
-	if err != nil {
-		panic(err)
+// Index job with bank and its document ID
+type indexJob struct {
+	id   string
+	bank *Bank
+}
+
+// Stream banks from CSV data file, validate and build the banks list.
+// If index is not nil then valid banks are also indexed concurrently by
+// given number of workers, each committing its own batches.
+func ingestBanks(i bleve.Index, dataPath string, batchSize int, workers int) (*ValidationReport, error) {
+	log.Info("Loading banks data.")
+
+	// Track load time
+	startTime := time.Now()
+
+	// Read banks data file
+	banksData, err := os.Open(dataPath)
+	if os.IsNotExist(err) {
+		return nil, &MissingDataError{Path: dataPath}
+	} else if err != nil {
+		return nil, err
 	}
-	defer banskData.Close()
+	defer banksData.Close()
 
-	banks := []*Bank{}
-	if err := gocsv.UnmarshalFile(banskData, &banks); err != nil {
-		panic(err)
+	dataInfo, err := banksData.Stat()
+	if err != nil {
+		return nil, err
 	}
 
-	for _, bank := range banks {
-		if bank.Abbreviation == "" {
-			continue
+	banks := make(chan *Bank, batchSize)
+	readErr := make(chan error, 1)
+	go func(c chan *Bank) {
+		readErr <- gocsv.UnmarshalToChan(banksData, c)
+	}(banks)
+
+	// Start index workers
+	var (
+		jobs       chan indexJob
+		workerErrs chan error
+		wg         sync.WaitGroup
+	)
+	if i != nil {
+		if workers < 1 {
+			workers = 1
 		}
 
-		// Check it its already there in bankslist
-		isThere := false
-		for _, item := range banksList {
-			if item.Abbreviation == bank.Abbreviation {
-				isThere = true
-				break
-			}
+		log.Infof("Indexing banks data with %d workers.", workers)
+		jobs = make(chan indexJob, batchSize*workers)
+		workerErrs = make(chan error, workers)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				workerErrs <- indexWorker(i, jobs, batchSize)
+			}()
 		}
+	}
 
-		if isThere {
-			continue
+	var (
+		list      []BanksList
+		seen      = make(map[string]bool)
+		count     = 0
+		line      = 1
+		validator = newBankValidator(dataPath, cfg().GetInt("validation_report_max_rows"))
+	)
+	for banks != nil {
+		select {
+		case bank, ok := <-banks:
+			if !ok {
+				banks = nil
+				continue
+			}
+
+			// Normalize and skip invalid rows
+			line++
+			if !validator.validate(line, bank) {
+				continue
+			}
+
+			if jobs != nil {
+				parseAddress(bank)
+				bank.Contacts = normalizeContacts(bank.Contact, bank.City, bank.District)
+				if bank.Latitude != 0 || bank.Longitude != 0 {
+					bank.Location = &GeoPoint{Lat: bank.Latitude, Lon: bank.Longitude}
+				}
+
+				jobs <- indexJob{id: bank.IFSC, bank: bank}
+			}
+			count++
+
+			// Add to banks list if its not already there
+			if bank.Abbreviation == "" || seen[bank.Abbreviation] {
+				continue
+			}
+
+			seen[bank.Abbreviation] = true
+			list = append(list, BanksList{
+				Abbreviation: bank.Abbreviation,
+				Name:         bank.Name,
+			})
+		case err = <-readErr:
+			readErr = nil
+			if err != nil {
+				banks = nil
+			}
 		}
+	}
 
-		banksList = append(banksList, BanksList{
-			Abbreviation: bank.Abbreviation,
-			Name:         bank.Name,
-		})
+	// Wait for reader to finish if channel was closed before its error was received
+	if readErr != nil {
+		err = <-readErr
 	}
 
-	return nil
-}
+	if err != nil {
+		err = &MalformedDataError{Path: dataPath, Err: err}
+	}
 
-// Create search index
-func indexBank(i bleve.Index, dataPath string, batchSize int) error {
-	log.Info("Indexing banks data.")
+	// Wait for index workers to commit pending batches
+	if jobs != nil {
+		close(jobs)
+		wg.Wait()
+		close(workerErrs)
 
-	// Track index time
-	startTime := time.Now()
-	// Create new index batch for bulk index
-	batch := i.NewBatch()
+		for werr := range workerErrs {
+			if werr != nil && err == nil {
+				err = &IndexError{Path: i.Name(), Err: werr}
+			}
+		}
+	}
 
-	// Read banks data file
-	banskData, err := os.OpenFile(dataPath, os.O_RDONLY, os.ModePerm)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	defer banskData.Close()
 
-	banks := []*Bank{}
-	if err := gocsv.UnmarshalFile(banskData, &banks); err != nil {
-		panic(err)
-	}
+	banksList = list
+	dataReleaseDate = dataInfo.ModTime()
 
-	count := 0
-	batchCount := 0
+	loadDuration := time.Since(startTime)
+	log.Infof("Loaded %d banks in %.2fs, skipped %d invalid rows", count, float64(loadDuration)/float64(time.Second), validator.report.InvalidRows)
+	return validator.report, nil
+}
 
-	for _, bank := range banks {
-		log.Infof("Indexing %v \n", bank)
-		batch.Index(strconv.Itoa(count), bank)
-		count++
-		batchCount++
+// Index banks from jobs channel in batches. After an error
+// remaining jobs are drained without indexing.
+func indexWorker(i bleve.Index, jobs <-chan indexJob, batchSize int) error {
+	var err error
 
-		if batchCount >= batchSize {
-			err = i.Batch(batch)
-			if err != nil {
-				return err
-			}
+	// Create new index batch for bulk index
+	batch := i.NewBatch()
+
+	for job := range jobs {
+		if err != nil {
+			continue
+		}
+
+		log.Debugf("Indexing %v", job.bank)
+		if err = batch.Index(job.id, job.bank); err != nil {
+			continue
+		}
 
+		if batch.Size() >= batchSize {
+			err = i.Batch(batch)
 			batch = i.NewBatch()
-			batchCount = 0
 		}
 	}
 
-	// If first batch never completes then commit the batch
-	if batchCount > 0 {
+	// Commit remaining documents
+	if err == nil && batch.Size() > 0 {
 		err = i.Batch(batch)
+	}
 
-		if err != nil {
-			log.Error(err)
+	return err
+}
+
+// Load per field boosts from config. Boosts are applied only at
+// query time so they can be reloaded without reindexing.
+func loadFieldBoosts() {
+	boosts := make(map[string]float64)
+	for _, field := range boostedFields {
+		boost := cfg().GetFloat64("boost." + field)
+		if boost < 0 {
+			log.Warnf("Ignoring negative boost %v for field %s", boost, field)
+			continue
 		}
+
+		boosts[field] = boost
 	}
 
-	indexDuration := time.Since(startTime)
-	indexDurationSeconds := float64(indexDuration) / float64(time.Second)
-	log.Infof("Indexed in %.2fs", indexDurationSeconds)
-	return nil
+	fieldBoostsMu.Lock()
+	fieldBoosts = boosts
+	fieldBoostsMu.Unlock()
+
+	log.Infof("Loaded field boosts: %v", boosts)
+}
+
+// Get the current field boosts
+func getFieldBoosts() map[string]float64 {
+	fieldBoostsMu.RLock()
+	defer fieldBoostsMu.RUnlock()
+
+	return fieldBoosts
 }
 
 // Check if the word is in list of excluded words
@@ -280,10 +766,62 @@ func processRawQuery(q string) (string, string) {
 	return strings.TrimSpace(formattedQuery), match
 }
 
-// Search for a query in the index
+// Build sort order for given sort option. Distance sort requires
+// latitude and longitude, and an index with bank locations. Ties are
+// broken by document ID so that the order is stable for search after
+// cursors. Score isn't part of field or distance sorts since cursors
+// only carry sort values which can't be compared with hit scores.
+func buildSortOrder(sortBy string, lat, lon *float64) (search.SortOrder, error) {
+	var sortOrder search.SortOrder
+
+	switch sortBy {
+	case "", sortScore:
+		sortOrder = append(sortOrder, &search.SortScore{Desc: true})
+	case sortDistance:
+		if lat == nil || lon == nil {
+			return nil, errors.New("Latitude and longitude are required to sort by distance")
+		}
+
+		if !indexHasLocations {
+			return nil, errors.New("Sort by distance is not available, banks data has no locations")
+		}
+
+		distanceSort, err := search.NewSortGeoDistance("location", "km", *lon, *lat, false)
+		if err != nil {
+			return nil, err
+		}
+
+		sortOrder = append(sortOrder, distanceSort)
+	default:
+		field, ok := sortFields[sortBy]
+		if !ok {
+			return nil, errors.New("Invalid sort option " + sortBy)
+		}
+
+		sortOrder = append(sortOrder, &search.SortField{Field: field})
+	}
+
+	return append(sortOrder, &search.SortDocID{}), nil
+}
+
+// Check if sort option supports search after cursors. Results sorted
+// by score can only be paged with page numbers.
+func isCursorSort(sortBy string) bool {
+	return sortBy != "" && sortBy != sortScore
+}
+
+// Build search query for raw query string.
 // Try to get the bank abbriviation from querystring using bankslist map
-// and perform conjuction query to retrive results
-func querySearch(q string, size int, from int) (*bleve.SearchResult, error) {
+// and create a conjuction query. Returns the query along with formatted
+// query and abbreviation detected from the raw query.
+func buildSearchQuery(q string) (query.Query, string, string) {
+	// Search by PIN code if the query is just a PIN code
+	if pincode, ok := parsePincodeQuery(q); ok {
+		pquery := bleve.NewTermQuery(pincode)
+		pquery.SetField("pincode")
+		return pquery, pincode, ""
+	}
+
 	// Get abbriviation and sanatized query string
 	formattedQuery, abb := processRawQuery(strings.ToLower(strings.TrimSpace(q)))
 
@@ -297,9 +835,26 @@ func querySearch(q string, size int, from int) (*bleve.SearchResult, error) {
 		dquery := bleve.NewDisjunctionQuery()
 		dquery.SetMin(1)
 
+		boosts := getFieldBoosts()
 		for _, w := range strings.Fields(strings.TrimSpace(formattedQuery)) {
-			query := bleve.NewTermQuery(w)
-			dquery.AddQuery(query)
+			// Match the word across all fields and additionally
+			// against boosted fields so that matches in them rank higher
+			wquery := bleve.NewDisjunctionQuery()
+			wquery.AddQuery(bleve.NewTermQuery(w))
+
+			for _, field := range boostedFields {
+				boost, ok := boosts[field]
+				if !ok || boost == 0 {
+					continue
+				}
+
+				tquery := bleve.NewTermQuery(w)
+				tquery.SetField(field)
+				tquery.SetBoost(boost)
+				wquery.AddQuery(tquery)
+			}
+
+			dquery.AddQuery(wquery)
 		}
 		cquery.AddQuery(dquery)
 	}
@@ -307,19 +862,66 @@ func querySearch(q string, size int, from int) (*bleve.SearchResult, error) {
 	// Add query to conjuction query if abbreiviation
 	// is available for given query
 	if abb != "" {
-		query := bleve.NewTermQuery(abb)
-		query.SetField("abbreviation")
-		cquery.AddQuery(query)
+		tquery := bleve.NewTermQuery(abb)
+		tquery.SetField("abbreviation")
+		cquery.AddQuery(tquery)
 	}
 
-	// Search index
-	search := bleve.NewSearchRequest(cquery)
-	search.Fields = []string{"*"}
-	search.From = from
-	search.Size = size
-	search.Explain = true
-	searchResults, err := bankIndex.Search(search)
+	return cquery, formattedQuery, abb
+}
+
+// Create a search request for given query. Results are ordered
+// by score if sort order is nil. If searchAfter is given then results
+// after given sort values are returned and from is ignored.
+func newSearchRequest(q query.Query, size int, from int, sortOrder search.SortOrder, searchAfter []string) *bleve.SearchRequest {
+	searchRequest := bleve.NewSearchRequest(q)
+	searchRequest.Fields = []string{"*"}
+	searchRequest.From = from
+	searchRequest.Size = size
+	if sortOrder != nil {
+		searchRequest.SortByCustom(sortOrder)
+	}
+
+	if len(searchAfter) > 0 {
+		searchRequest.From = 0
+		searchRequest.SearchAfter = searchAfter
+	}
 
+	return searchRequest
+}
+
+// Search banks index. List fields of hits with stored fields are always lists.
+func searchBanks(searchRequest *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	results, err := bankIndex.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hit := range results.Hits {
+		if hit.Fields == nil {
+			continue
+		}
+
+		for _, field := range listFields {
+			switch v := hit.Fields[field].(type) {
+			case []interface{}:
+			case nil:
+				hit.Fields[field] = []interface{}{}
+			default:
+				hit.Fields[field] = []interface{}{v}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Search for a query in the index
+func querySearch(q string, size int, from int, sortOrder search.SortOrder, searchAfter []string) (*bleve.SearchResult, error) {
+	searchQuery, _, _ := buildSearchQuery(q)
+
+	// Search index
+	searchResults, err := searchBanks(newSearchRequest(searchQuery, size, from, sortOrder, searchAfter))
 	if err != nil {
 		return nil, err
 	}
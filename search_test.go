@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search"
+	"github.com/spf13/viper"
+)
+
+const (
+	fixtureDataPath = "testdata/banks.csv"
+	goldenPath      = "testdata/golden.json"
+)
+
+// Golden queries file structure
+type goldenQueries struct {
+	TopK         int           `json:"top_k"`
+	MinPrecision float64       `json:"min_precision"`
+	MinMRR       float64       `json:"min_mrr"`
+	Queries      []goldenQuery `json:"queries"`
+}
+
+// Golden query and its expected IFSCs ordered by relevance
+type goldenQuery struct {
+	Query    string   `json:"query"`
+	Expected []string `json:"expected"`
+}
+
+// Build an in-memory index from fixture data before running tests
+func TestMain(m *testing.M) {
+	log.SetLevel(log.WarnLevel)
+
+	setDefaultConfig(viper.GetViper())
+	loadFieldBoosts()
+
+	indexMapping, err := buildIndexMapping()
+	if err != nil {
+		log.Fatalf("Error while building index mapping: %v", err)
+	}
+
+	bankIndex, err = bleve.NewMemOnly(indexMapping)
+	if err != nil {
+		log.Fatalf("Error while creating index: %v", err)
+	}
+
+	if _, err := ingestBanks(bankIndex, fixtureDataPath, 5, 2); err != nil {
+		log.Fatalf("Error while indexing fixture data: %v", err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestProcessRawQuery(t *testing.T) {
+	cases := []struct {
+		query        string
+		formatted    string
+		abbreviation string
+	}{
+		{"sbi jayanagar", "jayanagar", "sbin"},
+		{"sbi jp nagar", "jpnagar", "sbin"},
+		{"hdfc bank of koramangala", "koramangala", "hdfc"},
+		{"state bank of india", "state india", ""},
+		{"jp nagar", "jpnagar", ""},
+		{"mg road bangalore", "mgroad bangalore", ""},
+	}
+
+	for _, c := range cases {
+		formatted, abb := processRawQuery(c.query)
+		if formatted != c.formatted || abb != c.abbreviation {
+			t.Errorf("processRawQuery(%q) = (%q, %q), want (%q, %q)",
+				c.query, formatted, abb, c.formatted, c.abbreviation)
+		}
+	}
+}
+
+func TestBuildIndexMapping(t *testing.T) {
+	indexMapping, err := buildIndexMapping()
+	if err != nil {
+		t.Fatalf("Error while building index mapping: %v", err)
+	}
+
+	if err := indexMapping.Validate(); err != nil {
+		t.Fatalf("Invalid index mapping: %v", err)
+	}
+
+	analyzer := indexMapping.AnalyzerNamed("standard_analyzer")
+	if analyzer == nil {
+		t.Fatal("standard_analyzer is not registered")
+	}
+
+	terms := make(map[string]bool)
+	for _, token := range analyzer.Analyze([]byte("State Bank of India, JP Nagar")) {
+		terms[string(token.Term)] = true
+	}
+
+	for _, term := range []string{"state", "india", "jpnagar", "nag"} {
+		if !terms[term] {
+			t.Errorf("Expected term %q in analyzed tokens %v", term, terms)
+		}
+	}
+
+	for _, term := range []string{"bank", "of"} {
+		if terms[term] {
+			t.Errorf("Unexpected term %q in analyzed tokens", term)
+		}
+	}
+}
+
+func TestHasLocations(t *testing.T) {
+	if hasLocations(bankIndex) {
+		t.Error("Fixture index without locations reported to have locations")
+	}
+
+	indexMapping, err := buildIndexMapping()
+	if err != nil {
+		t.Fatalf("Error while building index mapping: %v", err)
+	}
+
+	index, err := bleve.NewMemOnly(indexMapping)
+	if err != nil {
+		t.Fatalf("Error while creating index: %v", err)
+	}
+	defer index.Close()
+
+	bank := &Bank{IFSC: "SBIN0003236", Location: &GeoPoint{Lat: 12.93, Lon: 77.58}}
+	if err := index.Index(bank.IFSC, bank); err != nil {
+		t.Fatalf("Error while indexing bank: %v", err)
+	}
+
+	if !hasLocations(index) {
+		t.Error("Index with bank location reported to have no locations")
+	}
+}
+
+// Index update fails if index was built with a different mapping
+func TestUpdateSearchIndexMapping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bankr-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexMapping, err := buildIndexMapping()
+	if err != nil {
+		t.Fatalf("Error while building index mapping: %v", err)
+	}
+
+	dataPath, indexPath := viper.GetString("data_path"), viper.GetString("search_index_path")
+	defer func() {
+		viper.Set("data_path", dataPath)
+		viper.Set("search_index_path", indexPath)
+	}()
+	viper.Set("data_path", fixtureDataPath)
+
+	for name, m := range map[string]mapping.IndexMapping{"current": indexMapping, "old": bleve.NewIndexMapping()} {
+		path := filepath.Join(dir, name)
+		index, err := bleve.New(path, m)
+		if err != nil {
+			t.Fatalf("Error while creating %s index: %v", name, err)
+		}
+		index.Close()
+
+		viper.Set("search_index_path", path)
+		err = updateSearchIndex()
+
+		if _, ok := err.(*IndexMappingError); ok != (name == "old") {
+			t.Errorf("Updating %s index: error = %v", name, err)
+		}
+	}
+}
+
+// Run golden queries against fixture index and report
+// precision (R-precision) and mean reciprocal rank
+func TestRelevance(t *testing.T) {
+	data, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Error while reading golden file: %v", err)
+	}
+
+	var golden goldenQueries
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatalf("Error while parsing golden file: %v", err)
+	}
+
+	var totalPrecision, totalRR float64
+	for _, g := range golden.Queries {
+		results, err := querySearch(g.Query, golden.TopK, 0, nil, nil)
+		if err != nil {
+			t.Fatalf("Error while searching %q: %v", g.Query, err)
+		}
+
+		precision, rr := scoreHits(results.Hits, g.Expected)
+		totalPrecision += precision
+		totalRR += rr
+
+		t.Logf("q=%-24q precision=%.2f rr=%.2f hits=%v", g.Query, precision, rr, hitIFSCs(results.Hits))
+	}
+
+	n := float64(len(golden.Queries))
+	precision, mrr := totalPrecision/n, totalRR/n
+	t.Logf("Queries: %d, precision: %.3f, MRR: %.3f", len(golden.Queries), precision, mrr)
+
+	if precision < golden.MinPrecision {
+		t.Errorf("Precision %.3f is below minimum %.3f", precision, golden.MinPrecision)
+	}
+
+	if mrr < golden.MinMRR {
+		t.Errorf("MRR %.3f is below minimum %.3f", mrr, golden.MinMRR)
+	}
+}
+
+// Compute precision of top len(expected) hits and reciprocal rank
+// of the first expected hit
+func scoreHits(hits search.DocumentMatchCollection, expected []string) (float64, float64) {
+	if len(expected) == 0 {
+		return 0, 0
+	}
+
+	isExpected := make(map[string]bool)
+	for _, ifsc := range expected {
+		isExpected[ifsc] = true
+	}
+
+	var (
+		relevant int
+		rr       float64
+	)
+	for i, ifsc := range hitIFSCs(hits) {
+		if !isExpected[ifsc] {
+			continue
+		}
+
+		if i < len(expected) {
+			relevant++
+		}
+
+		if rr == 0 {
+			rr = 1 / float64(i+1)
+		}
+	}
+
+	return float64(relevant) / float64(len(expected)), rr
+}
+
+// Get IFSC of search hits. IFSC is indexed with multiple
+// mappings so stored field can either be a string or list.
+func hitIFSCs(hits search.DocumentMatchCollection) []string {
+	ifscs := []string{}
+	for _, hit := range hits {
+		switch v := hit.Fields["IFSC"].(type) {
+		case string:
+			ifscs = append(ifscs, v)
+		case []interface{}:
+			if len(v) > 0 {
+				ifsc, _ := v[0].(string)
+				ifscs = append(ifscs, ifsc)
+			}
+		default:
+			ifscs = append(ifscs, "")
+		}
+	}
+
+	return ifscs
+}
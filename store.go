@@ -1 +1,55 @@
 package main
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// Local database for analytics and other app data
+	db *bolt.DB
+	// Buckets created when database is opened
+	analyticsBucket = []byte("analytics")
+	storeBuckets    = [][]byte{analyticsBucket, apiKeysBucket, apiUsageBucket}
+)
+
+// Open local database and create buckets
+func initStore() error {
+	path := cfg().GetString("db_path")
+
+	store, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return &StoreError{Path: path, Err: err}
+	}
+
+	err = store.Update(func(tx *bolt.Tx) error {
+		for _, name := range storeBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		store.Close()
+		return &StoreError{Path: path, Err: err}
+	}
+
+	db = store
+	return nil
+}
+
+// Close local database if its open
+func closeStore() error {
+	if db == nil {
+		return nil
+	}
+
+	log.Info("Closing database.")
+	err := db.Close()
+	db = nil
+	return err
+}
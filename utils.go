@@ -1 +1,24 @@
-package main
\ No newline at end of file
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Get total size of all files in a directory
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
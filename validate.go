@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// IFSC is 4 letter bank code, 0 and 6 character branch code
+	ifscRegexp = regexp.MustCompile("^[A-Z]{4}0[A-Z0-9]{6}$")
+	// MICR is 9 digits
+	micrRegexp = regexp.MustCompile("^[0-9]{9}$")
+	// Numbers stored as floats in exponent form or with a trailing zero
+	// decimal. For example 2.2261717E7 or 22261717.0
+	floatRegexp = regexp.MustCompile(`^([1-9][0-9]*(\.[0-9]+)?[eE][+]?[0-9]+|[1-9][0-9]*\.0+)$`)
+	// Placeholder values used for missing data
+	emptyValues = [...]string{"NA", "N/A", "N.A.", "NIL", "-", "0"}
+)
+
+// Largest integer a float holds exactly
+const maxExactFloat = 1 << 53
+
+// Validation issue types
+const (
+	issueMissingIFSC   = "missing_ifsc"
+	issueInvalidIFSC   = "invalid_ifsc"
+	issueDuplicateIFSC = "duplicate_ifsc"
+	issueMissingName   = "missing_name"
+	issueMissingBranch = "missing_branch"
+	issueInvalidMICR   = "invalid_micr"
+	issueNormalized    = "normalized"
+)
+
+// ValidationIssue is an issue found in a data row
+type ValidationIssue struct {
+	Line  int    `json:"line"`
+	IFSC  string `json:"ifsc"`
+	Issue string `json:"issue"`
+	Value string `json:"value,omitempty"`
+}
+
+// ValidationReport is a summary of data quality issues found on ingest
+type ValidationReport struct {
+	DataPath       string            `json:"data_path"`
+	TotalRows      int               `json:"total_rows"`
+	ValidRows      int               `json:"valid_rows"`
+	InvalidRows    int               `json:"invalid_rows"`
+	NormalizedRows int               `json:"normalized_rows"`
+	Counts         map[string]int    `json:"counts"`
+	Errors         []ValidationIssue `json:"errors"`
+	Warnings       []ValidationIssue `json:"warnings"`
+
+	// IFSCs of valid rows and their line number
+	ifscs map[string]int
+}
+
+// Validates and normalizes bank records and collects issues to a report
+type bankValidator struct {
+	report  *ValidationReport
+	maxRows int
+}
+
+// Create a new validator. Offending rows listed in
+// the report are limited to maxRows, counts are not.
+func newBankValidator(dataPath string, maxRows int) *bankValidator {
+	return &bankValidator{
+		report: &ValidationReport{
+			DataPath: dataPath,
+			Counts:   make(map[string]int),
+			Errors:   []ValidationIssue{},
+			Warnings: []ValidationIssue{},
+			ifscs:    make(map[string]int),
+		},
+		maxRows: maxRows,
+	}
+}
+
+// Record an issue. Errors make the row invalid.
+func (v *bankValidator) addIssue(issues *[]ValidationIssue, line int, bank *Bank, issue string, value string) {
+	v.report.Counts[issue]++
+	if len(*issues) < v.maxRows {
+		*issues = append(*issues, ValidationIssue{
+			Line:  line,
+			IFSC:  bank.IFSC,
+			Issue: issue,
+			Value: value,
+		})
+	}
+}
+
+// Normalize and validate bank in place. Returns false if the bank
+// has errors and shouldn't be indexed.
+func (v *bankValidator) validate(line int, bank *Bank) bool {
+	v.report.TotalRows++
+
+	if normalizeBank(bank) {
+		v.report.NormalizedRows++
+		v.report.Counts[issueNormalized]++
+	}
+
+	valid := true
+	addError := func(issue string, value string) {
+		v.addIssue(&v.report.Errors, line, bank, issue, value)
+		valid = false
+	}
+
+	// Mandatory fields
+	if bank.IFSC == "" {
+		addError(issueMissingIFSC, "")
+	} else if !ifscRegexp.MatchString(bank.IFSC) {
+		addError(issueInvalidIFSC, bank.IFSC)
+	} else if firstLine, ok := v.report.ifscs[bank.IFSC]; ok {
+		addError(issueDuplicateIFSC, fmt.Sprintf("first seen on line %d", firstLine))
+	}
+
+	if bank.Name == "" {
+		addError(issueMissingName, "")
+	}
+
+	if bank.Branch == "" {
+		addError(issueMissingBranch, "")
+	}
+
+	// Optional fields
+	if bank.MICR != "" && !micrRegexp.MatchString(bank.MICR) {
+		v.addIssue(&v.report.Warnings, line, bank, issueInvalidMICR, bank.MICR)
+	}
+
+	if valid {
+		v.report.ValidRows++
+		v.report.ifscs[bank.IFSC] = line
+	} else {
+		v.report.InvalidRows++
+	}
+
+	return valid
+}
+
+// Check if ratio of invalid rows is within maxInvalidRatio.
+// Threshold check is disabled if maxInvalidRatio is zero.
+func (r *ValidationReport) check(maxInvalidRatio float64) error {
+	if maxInvalidRatio <= 0 || r.TotalRows == 0 {
+		return nil
+	}
+
+	ratio := float64(r.InvalidRows) / float64(r.TotalRows)
+	if ratio > maxInvalidRatio {
+		return fmt.Errorf("%d of %d rows (%.2f%%) are invalid, maximum allowed is %.2f%%",
+			r.InvalidRows, r.TotalRows, ratio*100, maxInvalidRatio*100)
+	}
+
+	return nil
+}
+
+// Check if IFSC is in the valid rows
+func (r *ValidationReport) hasIFSC(ifsc string) bool {
+	_, ok := r.ifscs[ifsc]
+	return ok
+}
+
+// Write report as JSON to given path
+func (r *ValidationReport) write(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Trim and collapse whitespaces
+func cleanSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Clear placeholder values used for missing data
+func cleanEmpty(s string) string {
+	for _, e := range emptyValues {
+		if strings.EqualFold(s, e) {
+			return ""
+		}
+	}
+
+	return s
+}
+
+// Convert numbers stored as floats to integer strings. Only exponent
+// form and trailing zero decimals are converted, and only if the value
+// is an exact integer, so that other values are not rounded.
+func cleanFloat(s string) string {
+	if !floatRegexp.MatchString(s) {
+		return s
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || f > maxExactFloat {
+		return s
+	}
+
+	return strconv.FormatFloat(f, 'f', 0, 64)
+}
+
+// Normalize bank fields in place. Returns true if any field is changed.
+func normalizeBank(bank *Bank) bool {
+	// Keep raw contact for audit, contact is normalized to E.164 numbers on index
+	bank.ContactRaw = bank.Contact
+
+	changed := false
+	normalize := func(field *string, funcs ...func(string) string) {
+		value := *field
+		for _, f := range funcs {
+			value = f(value)
+		}
+
+		if value != *field {
+			*field = value
+			changed = true
+		}
+	}
+
+	normalize(&bank.Name, cleanSpaces, strings.ToUpper)
+	normalize(&bank.IFSC, cleanSpaces, strings.ToUpper)
+	normalize(&bank.MICR, cleanSpaces, cleanEmpty, cleanFloat)
+	normalize(&bank.Branch, cleanSpaces, strings.ToUpper)
+	normalize(&bank.Address, cleanSpaces, cleanEmpty, strings.ToUpper)
+	normalize(&bank.Contact, cleanSpaces, cleanEmpty, cleanFloat)
+	normalize(&bank.City, cleanSpaces, cleanEmpty, strings.ToUpper)
+	normalize(&bank.District, cleanSpaces, cleanEmpty, strings.ToUpper)
+	normalize(&bank.State, cleanSpaces, cleanEmpty, strings.ToUpper)
+	normalize(&bank.Abbreviation, cleanSpaces, strings.ToUpper)
+
+	return changed
+}
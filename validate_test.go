@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestCleanFloat(t *testing.T) {
+	cases := []struct {
+		value string
+		want  string
+	}{
+		{"2.2261717E7", "22261717"},
+		{"8.025536401E9", "8025536401"},
+		{"5.60002011e+08", "560002011"},
+		{"22261717.0", "22261717"},
+		{"1e3", "1000"},
+		{"080.22261717", "080.22261717"},
+		{"1.5", "1.5"},
+		{"22261717.5", "22261717.5"},
+		{"080.0", "080.0"},
+		{"1.2345E2", "1.2345E2"},
+		{"1.5E0", "1.5E0"},
+		{"1E30", "1E30"},
+		{"560002011", "560002011"},
+		{"0560002011", "0560002011"},
+		{"080-22261717", "080-22261717"},
+		{"(080) 2226 1717 / 9845012345", "(080) 2226 1717 / 9845012345"},
+		{"NA", "NA"},
+		{"N.A.", "N.A."},
+		{"E7", "E7"},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		if got := cleanFloat(c.value); got != c.want {
+			t.Errorf("cleanFloat(%q) = %q, want %q", c.value, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocarina/gocsv"
+	"github.com/tealeg/xlsx"
+)
+
+var (
+	// Matches all non alphanumeric characters in a header
+	headerCleanRegexp = regexp.MustCompile("[^A-Z0-9]")
+	// Normalized RBI header names for each bank field. Header names
+	// vary across data releases and sheets in the same workbook.
+	xlsxHeaders = map[string][]string{
+		"name":     {"BANK", "BANKNAME", "NAMEOFTHEBANK"},
+		"ifsc":     {"IFSC", "IFSCCODE", "IFSCODE"},
+		"micr":     {"MICR", "MICRCODE"},
+		"branch":   {"BRANCH", "BRANCHNAME", "OFFICE", "NAMEOFTHEBRANCH"},
+		"address":  {"ADDRESS", "BRANCHADDRESS"},
+		"contact":  {"CONTACT", "PHONE", "PHONENO", "TELEPHONE", "CONTACTNO"},
+		"std":      {"STD", "STDCODE"},
+		"city":     {"CITY", "CENTRE", "CENTER"},
+		"district": {"DISTRICT"},
+		"state":    {"STATE"},
+	}
+)
+
+// Normalize a header name by removing spaces and
+// punctuations. For example "IFSC Code" -> "IFSCCODE"
+func normalizeHeader(h string) string {
+	return headerCleanRegexp.ReplaceAllString(strings.ToUpper(h), "")
+}
+
+// Map header row columns to bank fields. Returns nil if
+// the row doesn't look like a header (doesn't have IFSC column).
+func mapXLSXHeader(row *xlsx.Row) map[string]int {
+	columns := make(map[string]int)
+	for i, cell := range row.Cells {
+		header := normalizeHeader(cell.String())
+		for field, names := range xlsxHeaders {
+			if _, ok := columns[field]; ok {
+				continue
+			}
+
+			for _, name := range names {
+				if header == name {
+					columns[field] = i
+					break
+				}
+			}
+		}
+	}
+
+	if _, ok := columns["ifsc"]; !ok {
+		return nil
+	}
+
+	return columns
+}
+
+// Get trimmed cell value of a field in the row
+func xlsxCellValue(row *xlsx.Row, columns map[string]int, field string) string {
+	i, ok := columns[field]
+	if !ok || i >= len(row.Cells) {
+		return ""
+	}
+
+	return strings.TrimSpace(row.Cells[i].String())
+}
+
+// Fill empty fields of bank with fields from other record of same IFSC
+func mergeBank(bank *Bank, other *Bank) {
+	fields := []struct{ dst, src *string }{
+		{&bank.Name, &other.Name},
+		{&bank.MICR, &other.MICR},
+		{&bank.Branch, &other.Branch},
+		{&bank.Address, &other.Address},
+		{&bank.Contact, &other.Contact},
+		{&bank.City, &other.City},
+		{&bank.District, &other.District},
+		{&bank.State, &other.State},
+	}
+
+	for _, f := range fields {
+		if *f.dst == "" {
+			*f.dst = *f.src
+		}
+	}
+}
+
+// Read banks from RBI IFSC and MICR XLSX workbooks. All sheets in
+// all workbooks are read and records with same IFSC are merged.
+func readBanksXLSX(paths []string) ([]*Bank, error) {
+	var (
+		banks  []*Bank
+		byIFSC = make(map[string]*Bank)
+	)
+
+	for _, path := range paths {
+		log.Infof("Reading XLSX file %s", path)
+
+		file, err := xlsx.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sheet := range file.Sheets {
+			var columns map[string]int
+			count := 0
+
+			for _, row := range sheet.Rows {
+				// Skip rows until header is found
+				if columns == nil {
+					columns = mapXLSXHeader(row)
+					continue
+				}
+
+				ifsc := strings.ToUpper(xlsxCellValue(row, columns, "ifsc"))
+				if ifsc == "" {
+					continue
+				}
+
+				contact := xlsxCellValue(row, columns, "contact")
+				if std := xlsxCellValue(row, columns, "std"); std != "" && contact != "" {
+					contact = std + "-" + contact
+				}
+
+				bank := &Bank{
+					Name:     xlsxCellValue(row, columns, "name"),
+					IFSC:     ifsc,
+					MICR:     xlsxCellValue(row, columns, "micr"),
+					Branch:   xlsxCellValue(row, columns, "branch"),
+					Address:  xlsxCellValue(row, columns, "address"),
+					Contact:  contact,
+					City:     xlsxCellValue(row, columns, "city"),
+					District: xlsxCellValue(row, columns, "district"),
+					State:    xlsxCellValue(row, columns, "state"),
+				}
+
+				// Bank abbreviation is the bank code part of IFSC
+				if len(ifsc) >= 4 {
+					bank.Abbreviation = ifsc[:4]
+				}
+
+				if existing, ok := byIFSC[ifsc]; ok {
+					mergeBank(existing, bank)
+				} else {
+					byIFSC[ifsc] = bank
+					banks = append(banks, bank)
+				}
+
+				count++
+			}
+
+			if columns == nil {
+				log.Warnf("Skipping sheet %s in %s, header not found.", sheet.Name, path)
+				continue
+			}
+
+			log.Infof("Read %d rows from sheet %s", count, sheet.Name)
+		}
+	}
+
+	if len(banks) == 0 {
+		return nil, errors.New("No bank records found in XLSX files")
+	}
+
+	return banks, nil
+}
+
+// Check if data file doesn't exist or older than any of the XLSX files
+func isDataStale(dataPath string, xlsxPaths []string) bool {
+	dataInfo, err := os.Stat(dataPath)
+	if err != nil {
+		return true
+	}
+
+	for _, path := range xlsxPaths {
+		info, err := os.Stat(path)
+		if err == nil && info.ModTime().After(dataInfo.ModTime()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Import RBI XLSX workbooks into CSV data file used for indexing
+func importXLSX(xlsxPaths []string, dataPath string) error {
+	banks, err := readBanksXLSX(xlsxPaths)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file in the same directory and rename it over data
+	// file once done, so that a failed import doesn't destroy existing data.
+	tmpFile, err := ioutil.TempFile(filepath.Dir(dataPath), "."+filepath.Base(dataPath)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	if err := gocsv.MarshalFile(&banks, tmpFile); err != nil {
+		return err
+	}
+
+	if err := tmpFile.Chmod(0644); err != nil {
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpFile.Name(), dataPath); err != nil {
+		return err
+	}
+
+	log.Infof("Imported %d banks to %s", len(banks), dataPath)
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Create workbook with sheets of given rows, in the given order
+func writeXLSXFixture(t *testing.T, path string, sheets [][][]string) {
+	file := xlsx.NewFile()
+	for i, rows := range sheets {
+		sheet, err := file.AddSheet("Sheet" + strconv.Itoa(i+1))
+		if err != nil {
+			t.Fatalf("Error while adding sheet: %v", err)
+		}
+
+		for _, values := range rows {
+			row := sheet.AddRow()
+			for _, v := range values {
+				row.AddCell().SetString(v)
+			}
+		}
+	}
+
+	if err := file.Save(path); err != nil {
+		t.Fatalf("Error while saving XLSX fixture: %v", err)
+	}
+}
+
+func TestMapXLSXHeader(t *testing.T) {
+	cases := []struct {
+		headers []string
+		want    map[string]int
+	}{
+		{
+			[]string{"BANK", "IFSC", "MICR", "BRANCH", "ADDRESS", "CONTACT", "CITY", "DISTRICT", "STATE"},
+			map[string]int{"name": 0, "ifsc": 1, "micr": 2, "branch": 3, "address": 4, "contact": 5, "city": 6, "district": 7, "state": 8},
+		},
+		{
+			[]string{"Name of the Bank", "IFSC Code", "MICR Code", "Branch Name", "Branch Address", "STD Code", "Phone No.", "Centre"},
+			map[string]int{"name": 0, "ifsc": 1, "micr": 2, "branch": 3, "address": 4, "std": 5, "contact": 6, "city": 7},
+		},
+		{
+			[]string{" ifs code ", "Office", "Telephone", "Center"},
+			map[string]int{"ifsc": 0, "branch": 1, "contact": 2, "city": 3},
+		},
+		// First matching column of a field is used
+		{
+			[]string{"IFSC", "Branch", "Office"},
+			map[string]int{"ifsc": 0, "branch": 1},
+		},
+		// Not a header row without IFSC column
+		{[]string{"List of bank branches"}, nil},
+		{[]string{"BANK", "BRANCH", "MICR"}, nil},
+		{[]string{}, nil},
+	}
+
+	sheet, err := xlsx.NewFile().AddSheet("Sheet1")
+	if err != nil {
+		t.Fatalf("Error while adding sheet: %v", err)
+	}
+
+	for _, c := range cases {
+		row := sheet.AddRow()
+		for _, h := range c.headers {
+			row.AddCell().SetString(h)
+		}
+
+		if got := mapXLSXHeader(row); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("mapXLSXHeader(%q) = %v, want %v", c.headers, got, c.want)
+		}
+	}
+}
+
+// Rows of same IFSC in different sheets are merged, first
+// non empty value of a field is kept
+func TestReadBanksXLSXMergesSheets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bankr-xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "banks.xlsx")
+	writeXLSXFixture(t, path, [][][]string{
+		{
+			{"List of IFSC codes"},
+			{"BANK", "IFSC", "BRANCH", "ADDRESS", "CITY"},
+			{"STATE BANK OF INDIA", "sbin0003236", "JAYANAGAR", "JAYANAGAR 4TH BLOCK", ""},
+			{"HDFC BANK", "HDFC0000053", "JAYANAGAR", "", "BANGALORE"},
+			{"", "", "EMPTY ROW", "", ""},
+		},
+		{
+			{"IFSC Code", "MICR Code", "STD Code", "Phone No.", "Centre", "Branch Name"},
+			{"SBIN0003236", "560002011", "080", "22261717", "BANGALORE", "IGNORED"},
+			{"HDFC0000053", "560240003", "", "61606161", "IGNORED", ""},
+			{"ICIC0000002", "560229002", "", "", "BANGALORE", "MG ROAD"},
+		},
+		{
+			{"Sheet without header"},
+			{"CNRB0000430", "MG ROAD"},
+		},
+	})
+
+	banks, err := readBanksXLSX([]string{path})
+	if err != nil {
+		t.Fatalf("Error while reading XLSX: %v", err)
+	}
+
+	want := []Bank{
+		{Name: "STATE BANK OF INDIA", IFSC: "SBIN0003236", MICR: "560002011", Branch: "JAYANAGAR",
+			Address: "JAYANAGAR 4TH BLOCK", Contact: "080-22261717", City: "BANGALORE", Abbreviation: "SBIN"},
+		{Name: "HDFC BANK", IFSC: "HDFC0000053", MICR: "560240003", Branch: "JAYANAGAR",
+			Contact: "61606161", City: "BANGALORE", Abbreviation: "HDFC"},
+		{IFSC: "ICIC0000002", MICR: "560229002", Branch: "MG ROAD", City: "BANGALORE", Abbreviation: "ICIC"},
+	}
+
+	if len(banks) != len(want) {
+		t.Fatalf("Read %d banks, want %d", len(banks), len(want))
+	}
+
+	for i, b := range banks {
+		if !reflect.DeepEqual(*b, want[i]) {
+			t.Errorf("Bank %d = %+v, want %+v", i, *b, want[i])
+		}
+	}
+}
+
+// Failed import keeps existing data file
+func TestImportXLSXKeepsDataOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bankr-xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataPath := filepath.Join(dir, "banks.csv")
+	if err := ioutil.WriteFile(dataPath, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "empty.xlsx")
+	writeXLSXFixture(t, path, [][][]string{{{"IFSC", "BANK"}}})
+
+	if err := importXLSX([]string{path}, dataPath); err == nil {
+		t.Fatal("Expected error on importing workbook without banks")
+	}
+
+	if data, _ := ioutil.ReadFile(dataPath); string(data) != "existing" {
+		t.Errorf("Data file changed to %q after failed import", data)
+	}
+
+	path = filepath.Join(dir, "banks.xlsx")
+	writeXLSXFixture(t, path, [][][]string{{{"IFSC", "BANK"}, {"SBIN0003236", "STATE BANK OF INDIA"}}})
+
+	if err := importXLSX([]string{path}, dataPath); err != nil {
+		t.Fatalf("Error while importing XLSX: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(dataPath)
+	if err != nil || !strings.HasPrefix(string(data), "BANK,IFSC,") || !strings.Contains(string(data), "STATE BANK OF INDIA,SBIN0003236,") {
+		t.Errorf("Imported data = %q, %v, want CSV with SBIN0003236", data, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, ".banks.csv.*"))
+	if len(files) != 0 {
+		t.Errorf("Temp files left after import: %v", files)
+	}
+}